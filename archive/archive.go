@@ -0,0 +1,246 @@
+//Package archive stores Zendesk community topics, posts, comments, votes, users and attachments in a directory,
+//so the community can be kept as a backup and migrated without access to Zendesk.
+//
+//Archive layout (format version 1):
+//
+//	manifest.json     - Manifest: format version, source Zendesk URL, topics, number of records and export errors
+//	topics.jsonl      - one TopicRecord per line
+//	posts.jsonl       - one PostRecord per line
+//	comments.jsonl    - one CommentRecord per line
+//	votes.jsonl       - one VoteRecord per line
+//	users.jsonl       - one zendesk.User per line, with its organization
+//	attachments.jsonl - one Attachment per line
+//	attachments/      - downloaded files referenced by attachments.jsonl
+//
+//Records contain fields of zendesk types as they are returned by Zendesk API
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/zendesk"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//Version is the format version written to manifest
+const Version = 1
+
+const (
+	manifestFile    = "manifest.json"
+	topicsFile      = "topics.jsonl"
+	postsFile       = "posts.jsonl"
+	commentsFile    = "comments.jsonl"
+	votesFile       = "votes.jsonl"
+	usersFile       = "users.jsonl"
+	attachmentsFile = "attachments.jsonl"
+	attachmentsDir  = "attachments"
+)
+
+//Manifest describes archive content
+type Manifest struct {
+	Version    int            `json:"version"`
+	CreatedAt  time.Time      `json:"created_at"`
+	ZendeskURL string         `json:"zendesk_url"`
+	Topics     []string       `json:"topics"`
+	Counts     map[string]int `json:"counts"`
+	//Errors are errors of posts and attachments which are missing or incomplete in the archive
+	Errors []ExportError `json:"errors,omitempty"`
+}
+
+//ExportError is an error of a post or attachment which is not exported or exported incomplete
+type ExportError struct {
+	Topic string `json:"topic"`
+	Error string `json:"error"`
+}
+
+//TopicRecord is a topic as it was requested (e.g. 115000153468-Integrations) with its details
+type TopicRecord struct {
+	Topic   string         `json:"topic"`
+	Details *zendesk.Topic `json:"details"`
+}
+
+//PostRecord is a post of a topic
+type PostRecord struct {
+	Topic string `json:"topic"`
+	*zendesk.Post
+}
+
+//CommentRecord is a comment of a post
+type CommentRecord struct {
+	PostID int64 `json:"post_id"`
+	*zendesk.Comment
+}
+
+//VoteRecord is a vote of a post
+type VoteRecord struct {
+	PostID int64 `json:"post_id"`
+	*zendesk.Vote
+}
+
+//Attachment is a file referenced by a post or comment body
+type Attachment struct {
+	URL       string `json:"url"`
+	File      string `json:"file"` // path relative to archive directory
+	PostID    int64  `json:"post_id"`
+	CommentID int64  `json:"comment_id,omitempty"`
+}
+
+//Writer writes an archive to a directory. It is safe for concurrent use
+type Writer struct {
+	dir      string
+	manifest Manifest
+	files    map[string]*os.File
+	users    map[int64]bool
+	mu       sync.Mutex
+}
+
+//Create creates an archive in dir. dir must not contain another archive
+func Create(dir string, zendeskURL string) (*Writer, error) {
+	if _, err := os.Stat(filepath.Join(dir, manifestFile)); err == nil {
+		return nil, fmt.Errorf("archive already exists in %s", dir)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, attachmentsDir), 0755); err != nil {
+		return nil, err
+	}
+	w := &Writer{
+		dir: dir,
+		manifest: Manifest{
+			Version:    Version,
+			CreatedAt:  time.Now().UTC(),
+			ZendeskURL: zendeskURL,
+			Topics:     make([]string, 0),
+			Counts:     make(map[string]int),
+		},
+		files: make(map[string]*os.File),
+		users: make(map[int64]bool),
+	}
+	for _, name := range []string{topicsFile, postsFile, commentsFile, votesFile, usersFile, attachmentsFile} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			w.closeFiles()
+			return nil, err
+		}
+		w.files[name] = f
+	}
+	return w, nil
+}
+
+//WriteTopic writes a topic
+func (w *Writer) WriteTopic(topic string, details *zendesk.Topic) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.manifest.Topics = append(w.manifest.Topics, topic)
+	return w.write(topicsFile, &TopicRecord{Topic: topic, Details: details})
+}
+
+//WritePost writes a post with its comments, votes and their users
+func (w *Writer) WritePost(topic string, post *zendesk.Post) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.write(postsFile, &PostRecord{Topic: topic, Post: post}); err != nil {
+		return err
+	}
+	if err := w.writeUser(post.Author); err != nil {
+		return err
+	}
+	for _, comment := range post.Comments {
+		if err := w.write(commentsFile, &CommentRecord{PostID: post.ID, Comment: comment}); err != nil {
+			return err
+		}
+		if err := w.writeUser(comment.Author); err != nil {
+			return err
+		}
+	}
+	for _, vote := range post.UserVotes {
+		if err := w.write(votesFile, &VoteRecord{PostID: post.ID, Vote: vote}); err != nil {
+			return err
+		}
+		if err := w.writeUser(vote.User); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//AttachmentPath returns a path to save attachment file to, and its name relative to archive directory
+func (w *Writer) AttachmentPath(name string) (string, string) {
+	rel := filepath.Join(attachmentsDir, name)
+	return filepath.Join(w.dir, rel), filepath.ToSlash(rel)
+}
+
+//WriteAttachment writes attachment record. Its file must be saved to a path returned by AttachmentPath
+func (w *Writer) WriteAttachment(attachment *Attachment) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.write(attachmentsFile, attachment)
+}
+
+//WriteError records an error of a post or attachment of the topic in manifest
+func (w *Writer) WriteError(topic string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.manifest.Errors = append(w.manifest.Errors, ExportError{Topic: topic, Error: err.Error()})
+}
+
+//Abort closes archive files without writing manifest, so the incomplete archive cannot be opened
+func (w *Writer) Abort() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closeFiles()
+}
+
+//Close writes manifest and closes archive files. It must be called only when all data is written
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.closeFiles(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(w.manifest, "", " ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(w.dir, manifestFile), data, 0644)
+}
+
+//Manifest returns manifest of written data
+func (w *Writer) Manifest() Manifest {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.manifest
+}
+
+func (w *Writer) writeUser(user *zendesk.User) error {
+	if user == nil || w.users[user.ID] {
+		return nil
+	}
+	w.users[user.ID] = true
+	return w.write(usersFile, user)
+}
+
+func (w *Writer) write(name string, record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := w.files[name].Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("cannot write %s: %w", name, err)
+	}
+	w.manifest.Counts[name]++
+	return nil
+}
+
+func (w *Writer) closeFiles() error {
+	var firstErr error
+	for name, f := range w.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(w.files, name)
+	}
+	return firstErr
+}
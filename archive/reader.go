@@ -0,0 +1,237 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
+	"github.com/Pleexy/zendesk-to-canny/zendesk"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+//Reader reads an archive created by Writer
+type Reader struct {
+	dir      string
+	manifest Manifest
+	users    map[int64]*zendesk.User
+	topics   map[string]*zendesk.Topic
+}
+
+//Open opens an archive in dir and loads its topics and users
+func Open(dir string) (*Reader, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read archive manifest, the export may be incomplete:%w", err)
+	}
+	r := &Reader{dir: dir, users: make(map[int64]*zendesk.User), topics: make(map[string]*zendesk.Topic)}
+	if err := json.Unmarshal(data, &r.manifest); err != nil {
+		return nil, fmt.Errorf("invalid archive manifest:%w", err)
+	}
+	if r.manifest.Version != Version {
+		return nil, fmt.Errorf("unsupported archive version %d, expected %d", r.manifest.Version, Version)
+	}
+	err = readRecords(filepath.Join(dir, usersFile), func(dec *json.Decoder) error {
+		var user zendesk.User
+		if err := dec.Decode(&user); err != nil {
+			return err
+		}
+		r.users[user.ID] = &user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readRecords(filepath.Join(dir, topicsFile), func(dec *json.Decoder) error {
+		var topic TopicRecord
+		if err := dec.Decode(&topic); err != nil {
+			return err
+		}
+		r.topics[topic.Topic] = topic.Details
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+//Manifest returns archive manifest
+func (r *Reader) Manifest() Manifest {
+	return r.manifest
+}
+
+//StreamPosts streams posts of a topic with comments, votes and users, in the order they were exported.
+//errCB is called with export errors of the topic recorded in manifest. parallel is not used,
+//it is accepted to be interchangeable with zendesk.Client
+func (r *Reader) StreamPosts(ctx context.Context, topic string, parallel int, errCB zendesk.PostLoadingErrorCallback) *zendesk.PostStream {
+	return zendesk.NewPostStream(func(postsCh chan<- *zendesk.Post) error {
+		if !r.hasTopic(topic) {
+			return fmt.Errorf("topic %s is not found in archive", topic)
+		}
+		for _, exportErr := range r.manifest.Errors {
+			if exportErr.Topic == topic && errCB != nil {
+				errCB(fmt.Errorf("archive is incomplete: %s", exportErr.Error))
+			}
+		}
+		posts, err := newRecordFile(filepath.Join(r.dir, postsFile))
+		if err != nil {
+			return err
+		}
+		defer posts.Close()
+		comments, err := newRecordFile(filepath.Join(r.dir, commentsFile))
+		if err != nil {
+			return err
+		}
+		defer comments.Close()
+		votes, err := newRecordFile(filepath.Join(r.dir, votesFile))
+		if err != nil {
+			return err
+		}
+		defer votes.Close()
+		// comments and votes are written grouped by post in the same order as posts
+		for {
+			var post PostRecord
+			if err := posts.next(&post); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			post.Post.Comments = make([]*zendesk.Comment, 0)
+			post.Post.UserVotes = make([]*zendesk.Vote, 0)
+			for {
+				var comment CommentRecord
+				if ok, err := comments.nextOf(post.ID, &comment, &comment.PostID); err != nil {
+					return err
+				} else if !ok {
+					break
+				}
+				comment.Author = r.users[comment.AuthorID]
+				post.Post.Comments = append(post.Post.Comments, comment.Comment)
+			}
+			for {
+				var vote VoteRecord
+				if ok, err := votes.nextOf(post.ID, &vote, &vote.PostID); err != nil {
+					return err
+				} else if !ok {
+					break
+				}
+				vote.User = r.users[vote.UserID]
+				post.Post.UserVotes = append(post.Post.UserVotes, vote.Vote)
+			}
+			if post.Topic != topic {
+				continue
+			}
+			post.Author = r.users[post.AuthorID]
+			select {
+			case postsCh <- post.Post:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+}
+
+func (r *Reader) hasTopic(topic string) bool {
+	for _, t := range r.manifest.Topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+//Posts implements feedback.Source
+func (r *Reader) Posts(ctx context.Context, topic string, errCB feedback.ErrorCallback) *feedback.Stream {
+	var topicName string
+	if details := r.topics[topic]; details != nil {
+		topicName = details.Name
+	}
+	return zendesk.FeedbackStream(ctx, topic, topicName, r.StreamPosts(ctx, topic, 0, nil))
+}
+
+//recordFile reads JSONL records one by one, allowing to look at the next record before consuming it
+type recordFile struct {
+	file    *os.File
+	scanner *bufio.Scanner
+	peeked  []byte
+}
+
+func newRecordFile(path string) (*recordFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return &recordFile{file: f, scanner: scanner}, nil
+}
+
+func (f *recordFile) line() ([]byte, error) {
+	if f.peeked != nil {
+		return f.peeked, nil
+	}
+	for f.scanner.Scan() {
+		if len(f.scanner.Bytes()) > 0 {
+			f.peeked = append([]byte(nil), f.scanner.Bytes()...)
+			return f.peeked, nil
+		}
+	}
+	if err := f.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+//next decodes the next record to dst
+func (f *recordFile) next(dst interface{}) error {
+	line, err := f.line()
+	if err != nil {
+		return err
+	}
+	f.peeked = nil
+	if err := json.Unmarshal(line, dst); err != nil {
+		return fmt.Errorf("invalid record in %s: %w", f.file.Name(), err)
+	}
+	return nil
+}
+
+//nextOf decodes the next record to dst if it belongs to the post. postID must point to the post ID field of dst
+func (f *recordFile) nextOf(post int64, dst interface{}, postID *int64) (bool, error) {
+	line, err := f.line()
+	if err == io.EOF {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(line, dst); err != nil {
+		return false, fmt.Errorf("invalid record in %s: %w", f.file.Name(), err)
+	}
+	if *postID != post {
+		return false, nil
+	}
+	f.peeked = nil
+	return true, nil
+}
+
+func (f *recordFile) Close() error {
+	return f.file.Close()
+}
+
+func readRecords(path string, decode func(dec *json.Decoder) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		if err := decode(dec); err != nil {
+			return fmt.Errorf("invalid record in %s: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package canny
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/httpclient"
 	"io/ioutil"
 	"net/http"
 	"time"
@@ -11,11 +13,13 @@ import (
 
 //CreatePost contains fields/params for a posts/create api call
 type CreatePost struct {
-	AuthorID  string   `json:"authorID"`
-	BoardID   string   `json:"boardID"`
-	Details   string   `json:"details"`
-	Title     string   `json:"title"`
-	ImageURLs []string `json:"imageURLs,omitempty"`
+	AuthorID     string                 `json:"authorID"`
+	BoardID      string                 `json:"boardID"`
+	CategoryID   string                 `json:"categoryID,omitempty"`
+	CustomFields map[string]interface{} `json:"customFields,omitempty"`
+	Details      string                 `json:"details"`
+	Title        string                 `json:"title"`
+	ImageURLs    []string               `json:"imageURLs,omitempty"`
 }
 
 type createPostRequest struct {
@@ -29,6 +33,7 @@ type CreateComment struct {
 	PostID    string   `json:"postID"`
 	Value     string   `json:"value"`
 	ImageURLs []string `json:"imageURLs,omitempty"`
+	Internal  bool     `json:"internal,omitempty"`
 	ParentID  string   `json:"parentID,omitempty"`
 }
 
@@ -48,37 +53,111 @@ type createVoteRequest struct {
 	CreateVote
 }
 
+//Company describes a company of a user for find_or_create api call
+type Company struct {
+	Created      time.Time `json:"created,omitempty"`
+	ID           string    `json:"id"`
+	MonthlySpend float64   `json:"monthlySpend,omitempty"`
+	Name         string    `json:"name"`
+}
+
 //FindOrCreateUser contains fields/params for find_or_create api call
 type FindOrCreateUser struct {
-	AvatarURL string    `json:"avatarURL,omitempty"`
-	Created   time.Time `json:"created,omitempty"`
-	Email     string    `json:"email,omitempty"`
-	Name      string    `json:"name,omitempty"`
-	UserID    string    `json:"userID,omitempty"`
+	AvatarURL    string                 `json:"avatarURL,omitempty"`
+	Companies    []Company              `json:"companies,omitempty"`
+	Created      time.Time              `json:"created,omitempty"`
+	CustomFields map[string]interface{} `json:"customFields,omitempty"`
+	Email        string                 `json:"email,omitempty"`
+	Name         string                 `json:"name,omitempty"`
+	UserID       string                 `json:"userID,omitempty"`
 }
 
 type findOrCreateUserRequest struct {
 	APIKey string `json:"apiKey"`
 	FindOrCreateUser
 }
+
+//User describes fields of a Canny user returned by users/list api call
+type User struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Email   string    `json:"email"`
+	IsAdmin bool      `json:"isAdmin"`
+	Name    string    `json:"name"`
+	UserID  string    `json:"userID"`
+}
+
+type listUsersRequest struct {
+	APIKey string `json:"apiKey"`
+	Limit  int    `json:"limit"`
+	Skip   int    `json:"skip"`
+}
+
+type listUsersResponse struct {
+	HasMore bool    `json:"hasMore"`
+	Users   []*User `json:"users"`
+}
+
+//Tag describes fields of a Canny post tag
+type Tag struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+//Category describes fields of a Canny post category
+type Category struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type listRequest struct {
+	APIKey  string `json:"apiKey"`
+	BoardID string `json:"boardID"`
+	Limit   int    `json:"limit"`
+	Skip    int    `json:"skip"`
+}
+
+type listTagsResponse struct {
+	HasMore bool   `json:"hasMore"`
+	Tags    []*Tag `json:"tags"`
+}
+
+type listCategoriesResponse struct {
+	HasMore    bool        `json:"hasMore"`
+	Categories []*Category `json:"categories"`
+}
+
+type createTagRequest struct {
+	APIKey  string `json:"apiKey"`
+	BoardID string `json:"boardID"`
+	Name    string `json:"name"`
+}
+
+type addTagRequest struct {
+	APIKey string `json:"apiKey"`
+	PostID string `json:"postID"`
+	TagID  string `json:"tagID"`
+}
+
 type response struct {
 	ID string
 }
 
 //Client provides methods to interact with canny.io api
 type Client struct {
-	APIKey  string
-	BaseURL string
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client // optional, httpclient.Default is used if not set. Custom http.RoundTripper can be provided as its Transport
 }
 
 // CreatePost create a new post in Canny and returns its id or error
-func (s *Client) CreatePost(post CreatePost) (string, error) {
+func (s *Client) CreatePost(ctx context.Context, post CreatePost) (string, error) {
 	req := &createPostRequest{
 		APIKey:     s.APIKey,
 		CreatePost: post,
 	}
 	var resp response
-	err := s.post(fmt.Sprintf("%s/api/v1/posts/create", s.BaseURL), req, &resp)
+	err := s.post(ctx, "posts/create", req, &resp)
 	if err != nil {
 		return "", err
 	}
@@ -86,13 +165,13 @@ func (s *Client) CreatePost(post CreatePost) (string, error) {
 }
 
 // CreateComment create a new comment in Canny and returns its id or error
-func (s *Client) CreateComment(comment CreateComment) (string, error) {
+func (s *Client) CreateComment(ctx context.Context, comment CreateComment) (string, error) {
 	req := &createCommentRequest{
 		APIKey:        s.APIKey,
 		CreateComment: comment,
 	}
 	var resp response
-	err := s.post(fmt.Sprintf("%s/api/v1/comments/create", s.BaseURL), req, &resp)
+	err := s.post(ctx, "comments/create", req, &resp)
 	if err != nil {
 		return "", err
 	}
@@ -100,13 +179,13 @@ func (s *Client) CreateComment(comment CreateComment) (string, error) {
 }
 
 // CreateVote create a new vote in Canny and returns its id or error
-func (s *Client) CreateVote(vote CreateVote) error {
+func (s *Client) CreateVote(ctx context.Context, vote CreateVote) error {
 	req := &createVoteRequest{
 		APIKey:     s.APIKey,
 		CreateVote: vote,
 	}
 	var resp string
-	err := s.post(fmt.Sprintf("%s/api/v1/votes/create", s.BaseURL), req, &resp)
+	err := s.post(ctx, "votes/create", req, &resp)
 	if err != nil {
 		return err
 	}
@@ -117,48 +196,162 @@ func (s *Client) CreateVote(vote CreateVote) error {
 }
 
 // FindOrCreateUser finds or creates a user
-func (s *Client) FindOrCreateUser(user FindOrCreateUser) (string, error) {
+func (s *Client) FindOrCreateUser(ctx context.Context, user FindOrCreateUser) (string, error) {
 	req := &findOrCreateUserRequest{
 		APIKey:           s.APIKey,
 		FindOrCreateUser: user,
 	}
 	var resp response
-	err := s.post(fmt.Sprintf("%s/api/v1/users/find_or_create", s.BaseURL), req, &resp)
+	err := s.post(ctx, "users/find_or_create", req, &resp)
 	if err != nil {
 		return "", err
 	}
 	return resp.ID, err
 }
 
-func (s *Client) post(url string, src interface{}, dst interface{}) error {
+// ListUsers returns all users of the company, including admins
+func (s *Client) ListUsers(ctx context.Context) ([]*User, error) {
+	users := make([]*User, 0)
+	req := &listUsersRequest{
+		APIKey: s.APIKey,
+		Limit:  100,
+	}
+	for {
+		var resp listUsersResponse
+		err := s.post(ctx, "users/list", req, &resp)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, resp.Users...)
+		if !resp.HasMore || len(resp.Users) == 0 {
+			return users, nil
+		}
+		req.Skip += len(resp.Users)
+	}
+}
+
+// ListTags returns all tags of the board
+func (s *Client) ListTags(ctx context.Context, boardID string) ([]*Tag, error) {
+	tags := make([]*Tag, 0)
+	req := &listRequest{
+		APIKey:  s.APIKey,
+		BoardID: boardID,
+		Limit:   100,
+	}
+	for {
+		var resp listTagsResponse
+		err := s.post(ctx, "tags/list", req, &resp)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, resp.Tags...)
+		if !resp.HasMore || len(resp.Tags) == 0 {
+			return tags, nil
+		}
+		req.Skip += len(resp.Tags)
+	}
+}
+
+// CreateTag creates a tag in the board and returns its id
+func (s *Client) CreateTag(ctx context.Context, boardID, name string) (string, error) {
+	req := &createTagRequest{
+		APIKey:  s.APIKey,
+		BoardID: boardID,
+		Name:    name,
+	}
+	var resp response
+	err := s.post(ctx, "tags/create", req, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// AddTag adds the tag to the post
+func (s *Client) AddTag(ctx context.Context, postID, tagID string) error {
+	req := &addTagRequest{
+		APIKey: s.APIKey,
+		PostID: postID,
+		TagID:  tagID,
+	}
+	var resp response
+	return s.post(ctx, "posts/add_tag", req, &resp)
+}
+
+// ListCategories returns all categories of the board
+func (s *Client) ListCategories(ctx context.Context, boardID string) ([]*Category, error) {
+	categories := make([]*Category, 0)
+	req := &listRequest{
+		APIKey:  s.APIKey,
+		BoardID: boardID,
+		Limit:   100,
+	}
+	for {
+		var resp listCategoriesResponse
+		err := s.post(ctx, "categories/list", req, &resp)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, resp.Categories...)
+		if !resp.HasMore || len(resp.Categories) == 0 {
+			return categories, nil
+		}
+		req.Skip += len(resp.Categories)
+	}
+}
+
+// CreateCategory creates a category in the board and returns its id
+func (s *Client) CreateCategory(ctx context.Context, boardID, name string) (string, error) {
+	req := &createTagRequest{
+		APIKey:  s.APIKey,
+		BoardID: boardID,
+		Name:    name,
+	}
+	var resp response
+	err := s.post(ctx, "categories/create", req, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (s *Client) post(ctx context.Context, endpoint string, src interface{}, dst interface{}) error {
+	url := fmt.Sprintf("%s/api/v1/%s", s.BaseURL, endpoint)
 	body, err := json.Marshal(src)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	cli := &http.Client{}
-	resp, err := cli.Do(req)
+	resp, err := s.httpClient().Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 	resBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("cannot read response body:%w, request:%s", err, body)
+		return fmt.Errorf("cannot read response body:%w, request:%s", err, redactRequest(body))
 	}
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("error while making request to %s: %d - %s, request:%s", url, resp.StatusCode, resBody, body)
+		return newAPIError(resp.StatusCode, endpoint, resBody, body)
 	}
 	if strDest, ok := dst.(*string); ok {
 		*strDest = string(resBody)
 	} else {
 		err = json.Unmarshal(resBody, dst)
 		if err != nil {
-			return fmt.Errorf("cannot unmarshal response to json:%w, response:%s, request:%s", err, resBody, body)
+			return fmt.Errorf("cannot unmarshal response to json:%w, response:%s, request:%s", err, resBody, redactRequest(body))
 		}
 	}
 	return nil
 }
+
+func (s *Client) httpClient() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
+	}
+	return httpclient.Default
+}
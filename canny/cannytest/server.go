@@ -0,0 +1,392 @@
+//Package cannytest provides an in-memory fake of Canny API used by canny.Client
+package cannytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/canny"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+//Post is a post created in the fake server
+type Post struct {
+	ID     string
+	TagIDs []string
+	canny.CreatePost
+}
+
+//Tag is a tag or a category of a board in the fake server
+type Tag struct {
+	ID      string
+	BoardID string
+	Name    string
+}
+
+//Comment is a comment created in the fake server
+type Comment struct {
+	ID string
+	canny.CreateComment
+}
+
+//User is a user created in the fake server
+type User struct {
+	ID      string
+	IsAdmin bool
+	canny.FindOrCreateUser
+}
+
+//Server is a fake Canny API. It implements posts/create, comments/create, votes/create, users/find_or_create, users/list,
+//tags/list, tags/create, posts/add_tag, categories/list and categories/create.
+//Use Lock/Unlock to access data fields while the server is in use
+type Server struct {
+	*httptest.Server
+	APIKey     string
+	Posts      []*Post
+	Comments   []*Comment
+	Votes      []canny.CreateVote
+	Users      []*User
+	Tags       []*Tag
+	Categories []*Tag
+	//Fail returns HTTP status code to fail a request to endpoint (e.g. posts/create) with,
+	// 0 serves the request normally. params contains decoded request body. Optional
+	Fail func(endpoint string, params map[string]interface{}) int
+
+	mu  sync.Mutex
+	seq int
+}
+
+//NewServer creates and starts a fake Canny server accepting apiKey. Use Server.URL as canny.Client BaseURL
+func NewServer(apiKey string) *Server {
+	s := &Server{APIKey: apiKey}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+//Lock locks server data
+func (s *Server) Lock() {
+	s.mu.Lock()
+}
+
+//Unlock unlocks server data
+func (s *Server) Unlock() {
+	s.mu.Unlock()
+}
+
+//PostByTitle returns a created post with the title or nil
+func (s *Server) PostByTitle(title string) *Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, post := range s.Posts {
+		if post.Title == title {
+			return post
+		}
+	}
+	return nil
+}
+
+//PostComments returns comments created for the post
+func (s *Server) PostComments(postID string) []*Comment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comments := make([]*Comment, 0)
+	for _, comment := range s.Comments {
+		if comment.PostID == postID {
+			comments = append(comments, comment)
+		}
+	}
+	return comments
+}
+
+//PostVotes returns votes created for the post
+func (s *Server) PostVotes(postID string) []canny.CreateVote {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	votes := make([]canny.CreateVote, 0)
+	for _, vote := range s.Votes {
+		if vote.PostID == postID {
+			votes = append(votes, vote)
+		}
+	}
+	return votes
+}
+
+//AddTag adds an existing tag to the board and returns its ID
+func (s *Server) AddTag(boardID, name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tag := &Tag{ID: s.nextID("tag"), BoardID: boardID, Name: name}
+	s.Tags = append(s.Tags, tag)
+	return tag.ID
+}
+
+//PostTags returns names of tags added to the post
+func (s *Server) PostTags(postID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0)
+	if post := s.findPost(postID); post != nil {
+		for _, tagID := range post.TagIDs {
+			if tag := findTag(s.Tags, tagID); tag != nil {
+				names = append(names, tag.Name)
+			}
+		}
+	}
+	return names
+}
+
+//CategoryName returns name of the category or empty string
+func (s *Server) CategoryName(categoryID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if category := findTag(s.Categories, categoryID); category != nil {
+		return category.Name
+	}
+	return ""
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoint := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "cannot read body")
+		return
+	}
+	var params map[string]interface{}
+	if r.Method != "POST" || json.Unmarshal(body, &params) != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+	if params["apiKey"] != s.APIKey {
+		writeError(w, http.StatusUnauthorized, "invalid api key")
+		return
+	}
+	if s.Fail != nil {
+		if status := s.Fail(endpoint, params); status != 0 {
+			writeError(w, status, "fake failure")
+			return
+		}
+	}
+	switch endpoint {
+	case "posts/create":
+		var req canny.CreatePost
+		_ = json.Unmarshal(body, &req)
+		if req.BoardID == "" || req.AuthorID == "" || req.Title == "" {
+			writeError(w, http.StatusBadRequest, "missing params")
+			return
+		}
+		if s.findUser(req.AuthorID) == nil {
+			writeError(w, http.StatusBadRequest, "invalid author")
+			return
+		}
+		if req.CategoryID != "" && findTag(s.Categories, req.CategoryID) == nil {
+			writeError(w, http.StatusBadRequest, "invalid category")
+			return
+		}
+		post := &Post{ID: s.nextID("post"), CreatePost: req}
+		s.Posts = append(s.Posts, post)
+		writeJSON(w, map[string]string{"id": post.ID})
+	case "comments/create":
+		var req canny.CreateComment
+		_ = json.Unmarshal(body, &req)
+		if s.findPost(req.PostID) == nil {
+			writeError(w, http.StatusBadRequest, "invalid post")
+			return
+		}
+		if s.findUser(req.AuthorID) == nil {
+			writeError(w, http.StatusBadRequest, "invalid author")
+			return
+		}
+		comment := &Comment{ID: s.nextID("comment"), CreateComment: req}
+		s.Comments = append(s.Comments, comment)
+		writeJSON(w, map[string]string{"id": comment.ID})
+	case "votes/create":
+		var req canny.CreateVote
+		_ = json.Unmarshal(body, &req)
+		if s.findPost(req.PostID) == nil {
+			writeError(w, http.StatusBadRequest, "invalid post")
+			return
+		}
+		if s.findUser(req.VoterID) == nil {
+			writeError(w, http.StatusBadRequest, "invalid voter")
+			return
+		}
+		for _, vote := range s.Votes {
+			if vote == req {
+				_, _ = w.Write([]byte("success"))
+				return
+			}
+		}
+		s.Votes = append(s.Votes, req)
+		_, _ = w.Write([]byte("success"))
+	case "users/find_or_create":
+		var req canny.FindOrCreateUser
+		_ = json.Unmarshal(body, &req)
+		if req.Email == "" && req.UserID == "" {
+			writeError(w, http.StatusBadRequest, "email or userID is required")
+			return
+		}
+		for _, user := range s.Users {
+			if (req.UserID != "" && user.UserID == req.UserID) || (req.Email != "" && user.Email == req.Email) {
+				writeJSON(w, map[string]string{"id": user.ID})
+				return
+			}
+		}
+		user := &User{ID: s.nextID("user"), FindOrCreateUser: req}
+		s.Users = append(s.Users, user)
+		writeJSON(w, map[string]string{"id": user.ID})
+	case "tags/list", "categories/list":
+		var req struct {
+			BoardID string `json:"boardID"`
+			Limit   int    `json:"limit"`
+			Skip    int    `json:"skip"`
+		}
+		_ = json.Unmarshal(body, &req)
+		if req.Limit <= 0 {
+			req.Limit = 10
+		}
+		all := s.Tags
+		name := "tags"
+		if endpoint == "categories/list" {
+			all, name = s.Categories, "categories"
+		}
+		items := make([]map[string]string, 0)
+		var boardItems int
+		for _, tag := range all {
+			if tag.BoardID != req.BoardID {
+				continue
+			}
+			if boardItems >= req.Skip && boardItems < req.Skip+req.Limit {
+				items = append(items, map[string]string{"id": tag.ID, "name": tag.Name})
+			}
+			boardItems++
+		}
+		writeJSON(w, map[string]interface{}{"hasMore": req.Skip+req.Limit < boardItems, name: items})
+	case "tags/create", "categories/create":
+		var req struct {
+			BoardID string `json:"boardID"`
+			Name    string `json:"name"`
+		}
+		_ = json.Unmarshal(body, &req)
+		if req.BoardID == "" || req.Name == "" {
+			writeError(w, http.StatusBadRequest, "missing params")
+			return
+		}
+		all, prefix := &s.Tags, "tag"
+		if endpoint == "categories/create" {
+			all, prefix = &s.Categories, "category"
+		}
+		for _, tag := range *all {
+			if tag.BoardID == req.BoardID && tag.Name == req.Name {
+				writeError(w, http.StatusBadRequest, "name already exists")
+				return
+			}
+		}
+		tag := &Tag{ID: s.nextID(prefix), BoardID: req.BoardID, Name: req.Name}
+		*all = append(*all, tag)
+		writeJSON(w, map[string]string{"id": tag.ID})
+	case "posts/add_tag":
+		var req struct {
+			PostID string `json:"postID"`
+			TagID  string `json:"tagID"`
+		}
+		_ = json.Unmarshal(body, &req)
+		post := s.findPost(req.PostID)
+		tag := findTag(s.Tags, req.TagID)
+		if post == nil || tag == nil || tag.BoardID != post.BoardID {
+			writeError(w, http.StatusBadRequest, "invalid post or tag")
+			return
+		}
+		for _, tagID := range post.TagIDs {
+			if tagID == tag.ID {
+				writeJSON(w, map[string]string{"id": post.ID})
+				return
+			}
+		}
+		post.TagIDs = append(post.TagIDs, tag.ID)
+		writeJSON(w, map[string]string{"id": post.ID})
+	case "users/list":
+		var req struct {
+			Limit int `json:"limit"`
+			Skip  int `json:"skip"`
+		}
+		_ = json.Unmarshal(body, &req)
+		if req.Limit <= 0 {
+			req.Limit = 10
+		}
+		users := make([]*canny.User, 0)
+		for i := req.Skip; i < len(s.Users) && i < req.Skip+req.Limit; i++ {
+			u := s.Users[i]
+			users = append(users, &canny.User{ID: u.ID, Created: u.Created, Email: u.Email, IsAdmin: u.IsAdmin, Name: u.Name, UserID: u.UserID})
+		}
+		writeJSON(w, map[string]interface{}{"hasMore": req.Skip+req.Limit < len(s.Users), "users": users})
+	default:
+		writeError(w, http.StatusNotFound, "invalid endpoint")
+	}
+}
+
+//AddUser adds an existing user and returns its ID
+func (s *Server) AddUser(user canny.FindOrCreateUser) string {
+	return s.addUser(user, false)
+}
+
+//AddAdmin adds an admin and returns its ID
+func (s *Server) AddAdmin(user canny.FindOrCreateUser) string {
+	return s.addUser(user, true)
+}
+
+func (s *Server) addUser(user canny.FindOrCreateUser, admin bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := &User{ID: s.nextID("user"), IsAdmin: admin, FindOrCreateUser: user}
+	s.Users = append(s.Users, u)
+	return u.ID
+}
+
+func (s *Server) nextID(prefix string) string {
+	s.seq++
+	return fmt.Sprintf("%s%d", prefix, s.seq)
+}
+
+func (s *Server) findPost(id string) *Post {
+	for _, post := range s.Posts {
+		if post.ID == id {
+			return post
+		}
+	}
+	return nil
+}
+
+func (s *Server) findUser(id string) *User {
+	for _, user := range s.Users {
+		if user.ID == id {
+			return user
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func findTag(tags []*Tag, id string) *Tag {
+	for _, tag := range tags {
+		if tag.ID == id {
+			return tag
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package canny
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	//ErrUnauthorized is matched by APIError when API key is invalid or has no access
+	ErrUnauthorized = errors.New("canny: unauthorized")
+	//ErrNotFound is matched by APIError when the requested object (board, post, user) doesn't exist
+	ErrNotFound = errors.New("canny: not found")
+	//ErrRateLimited is matched by APIError when too many requests are sent
+	ErrRateLimited = errors.New("canny: rate limited")
+	//ErrValidation is matched by APIError when request params are rejected
+	ErrValidation = errors.New("canny: validation error")
+)
+
+//APIError describes a non-200 response from Canny API.
+//Use errors.Is with ErrUnauthorized, ErrNotFound, ErrRateLimited or ErrValidation to check its kind
+type APIError struct {
+	StatusCode int
+	Endpoint   string // e.g. posts/create
+	Message    string // error message returned by Canny, if any
+	Body       string
+	Request    string // request params without the API key
+}
+
+func newAPIError(statusCode int, endpoint string, body, request []byte) *APIError {
+	var resp struct {
+		Error string `json:"error"`
+	}
+	_ = json.Unmarshal(body, &resp)
+	return &APIError{
+		StatusCode: statusCode,
+		Endpoint:   endpoint,
+		Message:    resp.Error,
+		Body:       string(body),
+		Request:    redactRequest(request),
+	}
+}
+
+func (e *APIError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = e.Body
+	}
+	if e.Request != "" {
+		return fmt.Sprintf("canny %s: %d - %s, request:%s", e.Endpoint, e.StatusCode, message, e.Request)
+	}
+	return fmt.Sprintf("canny %s: %d - %s", e.Endpoint, e.StatusCode, message)
+}
+
+//maxRequestSummary limits length of the request summary, post details can be long
+const maxRequestSummary = 1000
+
+//redactRequest returns JSON request params without the API key, for error messages
+func redactRequest(request []byte) string {
+	var params map[string]interface{}
+	if err := json.Unmarshal(request, &params); err != nil {
+		return ""
+	}
+	delete(params, "apiKey")
+	summary, err := json.Marshal(params)
+	if err != nil {
+		return ""
+	}
+	if len(summary) > maxRequestSummary {
+		return string(summary[:maxRequestSummary]) + "..."
+	}
+	return string(summary)
+}
+
+//Is reports whether the error matches one of sentinel errors
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrValidation:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
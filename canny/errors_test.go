@@ -0,0 +1,61 @@
+package canny_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Pleexy/zendesk-to-canny/canny"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	sentinels := []error{canny.ErrUnauthorized, canny.ErrNotFound, canny.ErrRateLimited, canny.ErrValidation}
+	tests := []struct {
+		status int
+		want   error // nil if no sentinel matches
+	}{
+		{status: http.StatusUnauthorized, want: canny.ErrUnauthorized},
+		{status: http.StatusForbidden, want: canny.ErrUnauthorized},
+		{status: http.StatusNotFound, want: canny.ErrNotFound},
+		{status: http.StatusTooManyRequests, want: canny.ErrRateLimited},
+		{status: http.StatusBadRequest, want: canny.ErrValidation},
+		{status: http.StatusUnprocessableEntity, want: canny.ErrValidation},
+		{status: http.StatusInternalServerError},
+		{status: http.StatusServiceUnavailable},
+	}
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			err := error(&canny.APIError{StatusCode: test.status, Endpoint: "posts/create"})
+			for _, sentinel := range sentinels {
+				if got := errors.Is(err, sentinel); got != (sentinel == test.want) {
+					t.Errorf("errors.Is(%d, %v) = %v", test.status, sentinel, got)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIErrorRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid board"}`))
+	}))
+	defer server.Close()
+	client := &canny.Client{APIKey: "secret-key", BaseURL: server.URL}
+	_, err := client.CreatePost(context.Background(), canny.CreatePost{BoardID: "board1", Title: "Dark mode"})
+	var apiErr *canny.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, canny.ErrValidation) {
+		t.Fatalf("expected validation APIError, got %v", err)
+	}
+	if apiErr.Message != "invalid board" {
+		t.Errorf("unexpected message '%s'", apiErr.Message)
+	}
+	if !strings.Contains(apiErr.Request, `"boardID":"board1"`) || !strings.Contains(err.Error(), `"title":"Dark mode"`) {
+		t.Errorf("request params are missing in the error: %v", err)
+	}
+	if strings.Contains(err.Error(), "secret-key") || strings.Contains(apiErr.Request, "apiKey") {
+		t.Errorf("API key must be removed from the error: %v", err)
+	}
+}
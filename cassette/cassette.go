@@ -0,0 +1,250 @@
+//Package cassette records HTTP exchanges with Zendesk and Canny to a directory and replays them,
+//so a migration can be reproduced and debugged without access to the original accounts
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+const redacted = "REDACTED"
+
+//secretHeaders are not saved to a cassette
+var secretHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+//secretFields are replaced in JSON request bodies
+var secretFields = []string{"apiKey"}
+
+//base64Encoding is ResponseEncoding of bodies which are not valid UTF-8, e.g. downloaded images
+const base64Encoding = "base64"
+
+//Interaction is a single recorded request and response
+type Interaction struct {
+	Method           string      `json:"method"`
+	URL              string      `json:"url"`
+	RequestBody      string      `json:"request_body,omitempty"`
+	StatusCode       int         `json:"status_code"`
+	ResponseHeader   http.Header `json:"response_header,omitempty"`
+	ResponseBody     string      `json:"response_body"`
+	ResponseEncoding string      `json:"response_encoding,omitempty"` // "base64" if ResponseBody is base64 encoded, e.g. an image
+}
+
+//setResponseBody saves body as is if it is valid UTF-8, or base64 encoded otherwise
+func (i *Interaction) setResponseBody(body []byte) {
+	if utf8.Valid(body) {
+		i.ResponseBody = string(body)
+		return
+	}
+	i.ResponseBody = base64.StdEncoding.EncodeToString(body)
+	i.ResponseEncoding = base64Encoding
+}
+
+//responseBody returns the decoded response body
+func (i *Interaction) responseBody() ([]byte, error) {
+	switch i.ResponseEncoding {
+	case "":
+		return []byte(i.ResponseBody), nil
+	case base64Encoding:
+		return base64.StdEncoding.DecodeString(i.ResponseBody)
+	default:
+		return nil, fmt.Errorf("unknown response encoding %s", i.ResponseEncoding)
+	}
+}
+
+//key identifies the request by method, path, query and body. Scheme and host are ignored,
+//so interactions can be replayed with other --z-url or --c-url than the recorded ones
+func (i *Interaction) key() string {
+	target := i.URL
+	if u, err := url.Parse(i.URL); err == nil {
+		target = u.RequestURI()
+	}
+	return i.Method + " " + target + "\n" + i.RequestBody
+}
+
+//Recorder is a http.RoundTripper that saves every exchange to Dir as a numbered JSON file, with secrets removed
+type Recorder struct {
+	Dir       string
+	Transport http.RoundTripper // underlying transport, http.DefaultTransport if nil
+
+	mu  sync.Mutex
+	seq int
+}
+
+//RoundTrip implements http.RoundTripper
+func (s *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+	transport := s.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	header := resp.Header.Clone()
+	for _, h := range secretHeaders {
+		header.Del(h)
+	}
+	interaction := &Interaction{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestBody:    redactBody(reqBody),
+		StatusCode:     resp.StatusCode,
+		ResponseHeader: header,
+	}
+	interaction.setResponseBody(respBody)
+	if err := s.save(interaction); err != nil {
+		return nil, fmt.Errorf("cannot record %s %s: %w", req.Method, req.URL, err)
+	}
+	return resp, nil
+}
+
+func (s *Recorder) save(interaction *Interaction) error {
+	data, err := json.MarshalIndent(interaction, "", " ")
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seq == 0 {
+		if err := os.MkdirAll(s.Dir, 0755); err != nil {
+			return err
+		}
+	}
+	s.seq++
+	return ioutil.WriteFile(filepath.Join(s.Dir, fmt.Sprintf("%06d.json", s.seq)), data, 0644)
+}
+
+//Replayer is a http.RoundTripper that serves responses recorded by Recorder.
+//Requests are matched by method, path, query and body with secrets removed. Identical requests get responses in recorded order
+type Replayer struct {
+	mu           sync.Mutex
+	interactions map[string][]*Interaction
+}
+
+//NewReplayer loads all interactions recorded in dir
+func NewReplayer(dir string) (*Replayer, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no recorded interactions found in %s", dir)
+	}
+	sort.Strings(files)
+	s := &Replayer{interactions: make(map[string][]*Interaction)}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var interaction Interaction
+		if err := json.Unmarshal(data, &interaction); err != nil {
+			return nil, fmt.Errorf("invalid interaction %s: %w", file, err)
+		}
+		if _, err := interaction.responseBody(); err != nil {
+			return nil, fmt.Errorf("invalid interaction %s: %w", file, err)
+		}
+		key := interaction.key()
+		s.interactions[key] = append(s.interactions[key], &interaction)
+	}
+	return s, nil
+}
+
+//RoundTrip implements http.RoundTripper
+func (s *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+	}
+	lookup := &Interaction{Method: req.Method, URL: req.URL.String(), RequestBody: redactBody(reqBody)}
+	key := lookup.key()
+	s.mu.Lock()
+	recorded := s.interactions[key]
+	if len(recorded) == 0 {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL)
+	}
+	interaction := recorded[0]
+	if len(recorded) > 1 {
+		// the last response is kept to serve repeated requests
+		s.interactions[key] = recorded[1:]
+	}
+	s.mu.Unlock()
+	body, err := interaction.responseBody()
+	if err != nil {
+		return nil, err
+	}
+	header := interaction.ResponseHeader
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.StatusCode, http.StatusText(interaction.StatusCode)),
+		StatusCode:    interaction.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+//redactBody replaces secret fields of a JSON object body
+func redactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return string(body)
+	}
+	changed := false
+	for _, field := range secretFields {
+		if _, ok := obj[field]; ok {
+			obj[field] = json.RawMessage(`"` + redacted + `"`)
+			changed = true
+		}
+	}
+	if !changed {
+		return string(body)
+	}
+	//map keys are marshalled sorted, so the result is stable for matching
+	redactedBody, err := json.Marshal(obj)
+	if err != nil {
+		return string(body)
+	}
+	return string(redactedBody)
+}
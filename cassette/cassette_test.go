@@ -0,0 +1,92 @@
+package cassette
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write([]byte(r.URL.Path + ":" + string(body)))
+	}))
+	dir := t.TempDir()
+	recorder := &http.Client{Transport: &Recorder{Dir: dir}}
+	req, _ := http.NewRequest("POST", srv.URL+"/api/v1/posts/create", strings.NewReader(`{"apiKey":"secret","title":"t"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := recorder.Do(req)
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	recorded, _ := ioutil.ReadAll(resp.Body)
+	srv.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("%d files recorded, want 1", len(files))
+	}
+	data, _ := ioutil.ReadFile(files[0])
+	if strings.Contains(string(data), `"apiKey":"secret"`) || strings.Contains(string(data), "Bearer") {
+		t.Errorf("secrets are not removed: %s", data)
+	}
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	cli := &http.Client{Transport: replayer}
+	resp, err = cli.Post(srv.URL+"/api/v1/posts/create", "application/json", strings.NewReader(`{"apiKey":"other","title":"t"}`))
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	replayed, _ := ioutil.ReadAll(resp.Body)
+	if string(replayed) != string(recorded) {
+		t.Errorf("replayed %q, want %q", replayed, recorded)
+	}
+	//the host is ignored, so another --z-url or --c-url can be used
+	resp, err = cli.Post("https://other.example.com/api/v1/posts/create", "application/json", strings.NewReader(`{"apiKey":"other","title":"t"}`))
+	if err != nil {
+		t.Fatalf("replay with another host: %v", err)
+	}
+	replayed, _ = ioutil.ReadAll(resp.Body)
+	if string(replayed) != string(recorded) {
+		t.Errorf("replayed %q with another host, want %q", replayed, recorded)
+	}
+	if _, err := cli.Get(srv.URL + "/unknown"); err == nil {
+		t.Error("request that is not recorded must fail")
+	}
+}
+
+func TestRecordReplayBinary(t *testing.T) {
+	image := []byte{0x89, 'P', 'N', 'G', 0xff, 0x00, 0xfe}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(image)
+	}))
+	dir := t.TempDir()
+	recorder := &http.Client{Transport: &Recorder{Dir: dir}}
+	resp, err := recorder.Get(srv.URL + "/hc/article_attachments/1/image.png")
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	resp.Body.Close()
+	srv.Close()
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	resp, err = (&http.Client{Transport: replayer}).Get(srv.URL + "/hc/article_attachments/1/image.png")
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	defer resp.Body.Close()
+	replayed, _ := ioutil.ReadAll(resp.Body)
+	if !bytes.Equal(replayed, image) {
+		t.Errorf("replayed %v, want %v", replayed, image)
+	}
+}
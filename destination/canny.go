@@ -0,0 +1,119 @@
+package destination
+
+import (
+	"context"
+	"github.com/Pleexy/zendesk-to-canny/canny"
+)
+
+//Canny writes to Canny
+type Canny struct {
+	Client *canny.Client
+}
+
+//FindOrCreateUser implements Destination
+func (s *Canny) FindOrCreateUser(ctx context.Context, user User) (string, error) {
+	var companies []canny.Company
+	for _, company := range user.Companies {
+		companies = append(companies, canny.Company{
+			Created:      company.Created,
+			ID:           company.ID,
+			MonthlySpend: company.MonthlySpend,
+			Name:         company.Name,
+		})
+	}
+	return s.Client.FindOrCreateUser(ctx, canny.FindOrCreateUser{
+		AvatarURL:    user.AvatarURL,
+		Companies:    companies,
+		Created:      user.Created,
+		CustomFields: user.CustomFields,
+		Email:        user.Email,
+		Name:         user.Name,
+		UserID:       user.UserID,
+	})
+}
+
+//CreatePost implements Destination
+func (s *Canny) CreatePost(ctx context.Context, post Post) (string, error) {
+	return s.Client.CreatePost(ctx, canny.CreatePost{
+		AuthorID:     post.AuthorID,
+		BoardID:      post.BoardID,
+		CategoryID:   post.CategoryID,
+		CustomFields: post.CustomFields,
+		Details:      post.Details,
+		Title:        post.Title,
+	})
+}
+
+//CreateComment implements Destination
+func (s *Canny) CreateComment(ctx context.Context, comment Comment) (string, error) {
+	return s.Client.CreateComment(ctx, canny.CreateComment{
+		AuthorID: comment.AuthorID,
+		Internal: comment.Internal,
+		PostID:   comment.PostID,
+		Value:    comment.Value,
+	})
+}
+
+//CreateVote implements Destination
+func (s *Canny) CreateVote(ctx context.Context, vote Vote) error {
+	return s.Client.CreateVote(ctx, canny.CreateVote{
+		PostID:  vote.PostID,
+		VoterID: vote.VoterID,
+	})
+}
+
+//ListAdmins implements AdminLister
+func (s *Canny) ListAdmins(ctx context.Context) ([]Admin, error) {
+	users, err := s.Client.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	admins := make([]Admin, 0)
+	for _, user := range users {
+		if user.IsAdmin {
+			admins = append(admins, Admin{ID: user.ID, Name: user.Name, Email: user.Email})
+		}
+	}
+	return admins, nil
+}
+
+//ListTags implements Organizer
+func (s *Canny) ListTags(ctx context.Context, boardID string) ([]Label, error) {
+	tags, err := s.Client.ListTags(ctx, boardID)
+	if err != nil {
+		return nil, err
+	}
+	labels := make([]Label, 0, len(tags))
+	for _, tag := range tags {
+		labels = append(labels, Label{ID: tag.ID, Name: tag.Name})
+	}
+	return labels, nil
+}
+
+//CreateTag implements Organizer
+func (s *Canny) CreateTag(ctx context.Context, boardID, name string) (string, error) {
+	return s.Client.CreateTag(ctx, boardID, name)
+}
+
+//AddTag implements Organizer
+func (s *Canny) AddTag(ctx context.Context, postID, tagID string) error {
+	return s.Client.AddTag(ctx, postID, tagID)
+}
+
+//ListCategories implements Organizer
+func (s *Canny) ListCategories(ctx context.Context, boardID string) ([]Label, error) {
+	categories, err := s.Client.ListCategories(ctx, boardID)
+	if err != nil {
+		return nil, err
+	}
+	labels := make([]Label, 0, len(categories))
+	for _, category := range categories {
+		labels = append(labels, Label{ID: category.ID, Name: category.Name})
+	}
+	return labels, nil
+}
+
+//CreateCategory implements Organizer
+func (s *Canny) CreateCategory(ctx context.Context, boardID, name string) (string, error) {
+	return s.Client.CreateCategory(ctx, boardID, name)
+}
@@ -0,0 +1,86 @@
+//Package destination defines where migrated posts, comments and votes are written to:
+//Canny, or Markdown and JSON files for a review before the import
+package destination
+
+import (
+	"context"
+	"time"
+)
+
+//User is a user to find or create
+type User struct {
+	Name         string                 `json:"name,omitempty"`
+	Email        string                 `json:"email,omitempty"`
+	UserID       string                 `json:"userID,omitempty"` // external (SSO) user ID
+	AvatarURL    string                 `json:"avatarURL,omitempty"`
+	Created      time.Time              `json:"created,omitempty"`
+	CustomFields map[string]interface{} `json:"customFields,omitempty"`
+	Companies    []Company              `json:"companies,omitempty"`
+}
+
+//Company is a company of a user
+type Company struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Created      time.Time `json:"created,omitempty"`
+	MonthlySpend float64   `json:"monthlySpend,omitempty"`
+}
+
+//Post is a post to create
+type Post struct {
+	BoardID      string                 `json:"boardID,omitempty"`
+	AuthorID     string                 `json:"authorID,omitempty"`
+	CategoryID   string                 `json:"categoryID,omitempty"`
+	Title        string                 `json:"title,omitempty"`
+	Details      string                 `json:"details,omitempty"`
+	CustomFields map[string]interface{} `json:"customFields,omitempty"`
+}
+
+//Comment is a comment to create
+type Comment struct {
+	PostID   string `json:"postID,omitempty"`
+	AuthorID string `json:"authorID,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Internal bool   `json:"internal,omitempty"` // visible to admins only
+}
+
+//Vote is a vote to create
+type Vote struct {
+	PostID  string `json:"postID,omitempty"`
+	VoterID string `json:"voterID,omitempty"`
+}
+
+//Destination resolves users and creates posts, comments and votes. IDs returned by it are saved to migration state
+type Destination interface {
+	FindOrCreateUser(ctx context.Context, user User) (string, error)
+	CreatePost(ctx context.Context, post Post) (string, error)
+	CreateComment(ctx context.Context, comment Comment) (string, error)
+	CreateVote(ctx context.Context, vote Vote) error
+}
+
+//Admin is an existing admin of a destination
+type Admin struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+//AdminLister is implemented by destinations which can list their admins, to map source agents to them by email
+type AdminLister interface {
+	ListAdmins(ctx context.Context) ([]Admin, error)
+}
+
+//Label is a tag or a category of a board
+type Label struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+//Organizer is implemented by destinations which support post tags and categories
+type Organizer interface {
+	ListTags(ctx context.Context, boardID string) ([]Label, error)
+	CreateTag(ctx context.Context, boardID, name string) (string, error)
+	AddTag(ctx context.Context, postID, tagID string) error
+	ListCategories(ctx context.Context, boardID string) ([]Label, error)
+	CreateCategory(ctx context.Context, boardID, name string) (string, error)
+}
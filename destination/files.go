@@ -0,0 +1,265 @@
+package destination
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//Files writes every post with its comments and votes to <board>/<post id>.md for a review, and to <post id>.json next to it.
+//Users are written to users.json. Post IDs are built from titles, so they are readable in a file list
+type Files struct {
+	Dir string
+
+	mu    sync.Mutex
+	users map[string]*FileUser
+	posts map[string]*FilePost
+}
+
+//FileUser is a user written to users.json
+type FileUser struct {
+	ID string `json:"id"`
+	User
+}
+
+//FilePost is a post written to <post id>.json
+type FilePost struct {
+	ID string `json:"id"`
+	Post
+	Tags     []string       `json:"tags,omitempty"`
+	Comments []*FileComment `json:"comments"`
+	Voters   []string       `json:"voters"`
+}
+
+//FileComment is a comment of a FilePost
+type FileComment struct {
+	ID string `json:"id"`
+	Comment
+}
+
+var slugRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+//FindOrCreateUser implements Destination
+func (s *Files) FindOrCreateUser(ctx context.Context, user User) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return "", err
+	}
+	id := user.UserID
+	if id == "" {
+		id = user.Email
+	}
+	if id == "" {
+		return "", fmt.Errorf("email or userID is required")
+	}
+	if _, ok := s.users[id]; !ok {
+		s.users[id] = &FileUser{ID: id, User: user}
+		if err := writeJSON(filepath.Join(s.Dir, "users.json"), s.users); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+//CreatePost implements Destination
+func (s *Files) CreatePost(ctx context.Context, post Post) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return "", err
+	}
+	slug := strings.Trim(slugRegexp.ReplaceAllString(strings.ToLower(post.Title), "-"), "-")
+	if len(slug) > 60 {
+		slug = strings.TrimRight(slug[:60], "-")
+	}
+	if slug == "" {
+		slug = "post"
+	}
+	id := post.BoardID + "/" + slug
+	for i := 2; s.postExists(id); i++ {
+		id = fmt.Sprintf("%s/%s-%d", post.BoardID, slug, i)
+	}
+	p := &FilePost{ID: id, Post: post, Comments: make([]*FileComment, 0), Voters: make([]string, 0)}
+	if err := s.writePost(p); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+//CreateComment implements Destination
+func (s *Files) CreateComment(ctx context.Context, comment Comment) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, err := s.post(comment.PostID)
+	if err != nil {
+		return "", err
+	}
+	id := fmt.Sprintf("%s#%d", post.ID, len(post.Comments)+1)
+	post.Comments = append(post.Comments, &FileComment{ID: id, Comment: comment})
+	return id, s.writePost(post)
+}
+
+//CreateVote implements Destination
+func (s *Files) CreateVote(ctx context.Context, vote Vote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, err := s.post(vote.PostID)
+	if err != nil {
+		return err
+	}
+	for _, voter := range post.Voters {
+		if voter == vote.VoterID {
+			return nil
+		}
+	}
+	post.Voters = append(post.Voters, vote.VoterID)
+	return s.writePost(post)
+}
+
+//ListTags implements Organizer. Tags are not listed, their IDs are their names
+func (s *Files) ListTags(ctx context.Context, boardID string) ([]Label, error) {
+	return nil, nil
+}
+
+//CreateTag implements Organizer
+func (s *Files) CreateTag(ctx context.Context, boardID, name string) (string, error) {
+	return name, nil
+}
+
+//AddTag implements Organizer
+func (s *Files) AddTag(ctx context.Context, postID, tagID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, err := s.post(postID)
+	if err != nil {
+		return err
+	}
+	for _, tag := range post.Tags {
+		if tag == tagID {
+			return nil
+		}
+	}
+	post.Tags = append(post.Tags, tagID)
+	return s.writePost(post)
+}
+
+//ListCategories implements Organizer. Categories are not listed, their IDs are their names
+func (s *Files) ListCategories(ctx context.Context, boardID string) ([]Label, error) {
+	return nil, nil
+}
+
+//CreateCategory implements Organizer
+func (s *Files) CreateCategory(ctx context.Context, boardID, name string) (string, error) {
+	return name, nil
+}
+
+//load reads users written by a previous run
+func (s *Files) load() error {
+	if s.users != nil {
+		return nil
+	}
+	s.users = make(map[string]*FileUser)
+	s.posts = make(map[string]*FilePost)
+	data, err := ioutil.ReadFile(filepath.Join(s.Dir, "users.json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &s.users)
+}
+
+func (s *Files) postExists(id string) bool {
+	if _, ok := s.posts[id]; ok {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(s.Dir, id+".json"))
+	return err == nil
+}
+
+//post returns a post created by this or a previous run
+func (s *Files) post(id string) (*FilePost, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if post, ok := s.posts[id]; ok {
+		return post, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(s.Dir, id+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("post %s is not found: %w", id, err)
+	}
+	var post FilePost
+	if err := json.Unmarshal(data, &post); err != nil {
+		return nil, fmt.Errorf("invalid post %s: %w", id, err)
+	}
+	s.posts[id] = &post
+	return &post, nil
+}
+
+func (s *Files) writePost(post *FilePost) error {
+	path := filepath.Join(s.Dir, post.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := writeJSON(path+".json", post); err != nil {
+		return err
+	}
+	s.posts[post.ID] = post
+	return ioutil.WriteFile(path+".md", s.markdown(post), 0644)
+}
+
+func (s *Files) markdown(post *FilePost) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", post.Title)
+	fmt.Fprintf(&buf, "_Posted by %s to board %s · %d votes · %d comments_\n\n", s.userName(post.AuthorID), post.BoardID, len(post.Voters), len(post.Comments))
+	if post.CategoryID != "" {
+		fmt.Fprintf(&buf, "Category: %s\n\n", post.CategoryID)
+	}
+	if len(post.Tags) > 0 {
+		fmt.Fprintf(&buf, "Tags: %s\n\n", strings.Join(post.Tags, ", "))
+	}
+	buf.WriteString(strings.TrimSpace(post.Details))
+	buf.WriteString("\n")
+	if len(post.Comments) > 0 {
+		buf.WriteString("\n## Comments\n")
+		for _, comment := range post.Comments {
+			internal := ""
+			if comment.Internal {
+				internal = " (internal)"
+			}
+			fmt.Fprintf(&buf, "\n**%s%s:**\n\n%s\n", s.userName(comment.AuthorID), internal, strings.TrimSpace(comment.Value))
+		}
+	}
+	if len(post.Voters) > 0 {
+		buf.WriteString("\n## Voters\n\n")
+		for _, voter := range post.Voters {
+			fmt.Fprintf(&buf, "- %s\n", s.userName(voter))
+		}
+	}
+	return buf.Bytes()
+}
+
+func (s *Files) userName(id string) string {
+	if user, ok := s.users[id]; ok && user.Name != "" {
+		return user.Name
+	}
+	return id
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", " ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/archive"
+	"github.com/Pleexy/zendesk-to-canny/zendesk"
+	flag "github.com/spf13/pflag"
+	"html"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+)
+
+//Export contains export parameters and methods
+type Export struct {
+	ZClient      *zendesk.Client
+	Topics       []string
+	Dir          string
+	ParallelLoad int
+	Attachments  bool // download images referenced by posts and comments
+	Verbose      bool
+	Logger       *log.Logger
+	downloaded   map[string]string // attachment url to archive file
+}
+
+var imgSrcRegexp = regexp.MustCompile(`(?i)<img[^>]+src\s*=\s*["']([^"']+)["']`)
+
+//Export writes specified topics to an archive
+func (s *Export) Export(ctx context.Context) error {
+	w, err := archive.Create(s.Dir, s.ZClient.BaseURL)
+	if err != nil {
+		return fmt.Errorf("cannot create archive:%w", err)
+	}
+	s.downloaded = make(map[string]string)
+	if exportErr := s.exportTopics(ctx, w); exportErr != nil {
+		// manifest is not written, so the incomplete archive is not used as a source
+		_ = w.Abort()
+		return fmt.Errorf("export is incomplete, manifest is not written:%w", exportErr)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("cannot write archive:%w", err)
+	}
+	manifest := w.Manifest()
+	s.Logger.Printf("Exported %d topics to %s: %v", len(manifest.Topics), s.Dir, manifest.Counts)
+	if len(manifest.Errors) > 0 {
+		s.Logger.Printf("%d posts or attachments are missing or incomplete, errors are recorded in the manifest", len(manifest.Errors))
+	}
+	if s.Verbose {
+		printStats(s.Logger, s.ZClient.Stats())
+	}
+	return nil
+}
+
+func (s *Export) exportTopics(ctx context.Context, w *archive.Writer) error {
+	for _, zTopic := range s.Topics {
+		topic, err := s.ZClient.GetTopic(ctx, zTopic)
+		if err != nil {
+			return err
+		}
+		if err := w.WriteTopic(zTopic, topic); err != nil {
+			return err
+		}
+		s.Logger.Printf("Exporting topic '%s'", zTopic)
+		var posts, loadErrors int
+		stream := s.ZClient.StreamPosts(ctx, zTopic, s.ParallelLoad, func(err error) {
+			loadErrors++
+			s.Logger.Printf("\tError:%v", err)
+			w.WriteError(zTopic, err)
+		})
+		var writeErr error
+		for post := range stream.Posts {
+			if writeErr != nil {
+				continue // drain the stream
+			}
+			if writeErr = w.WritePost(zTopic, post); writeErr != nil {
+				continue
+			}
+			if s.Attachments {
+				s.downloadAttachments(ctx, w, zTopic, post)
+			}
+			if s.Verbose {
+				s.Logger.Printf("\tExported post %d: '%s' with %d comments and %d votes", post.ID, post.Title, len(post.Comments), len(post.UserVotes))
+			}
+			posts++
+		}
+		if err := stream.Err(); err != nil {
+			return fmt.Errorf("cannot load posts for %s: %w", zTopic, err)
+		}
+		if writeErr != nil {
+			return writeErr
+		}
+		s.Logger.Printf("Exported topic '%s': %d posts, %d errors", zTopic, posts, loadErrors)
+	}
+	return nil
+}
+
+//downloadAttachments downloads images of the post and its comments. Failed downloads are recorded in manifest
+func (s *Export) downloadAttachments(ctx context.Context, w *archive.Writer, topic string, post *zendesk.Post) {
+	s.downloadImages(ctx, w, topic, post.Details, post.ID, 0)
+	for _, comment := range post.Comments {
+		s.downloadImages(ctx, w, topic, comment.Body, post.ID, comment.ID)
+	}
+}
+
+func (s *Export) downloadImages(ctx context.Context, w *archive.Writer, topic, body string, postID, commentID int64) {
+	for _, match := range imgSrcRegexp.FindAllStringSubmatch(body, -1) {
+		src := html.UnescapeString(match[1])
+		file, ok := s.downloaded[src]
+		if !ok {
+			var err error
+			file, err = s.download(ctx, w, src)
+			if err != nil {
+				err = fmt.Errorf("cannot download %s of post %d: %w", src, postID, err)
+				s.Logger.Printf("\tError: %v", err)
+				w.WriteError(topic, err)
+				continue
+			}
+			s.downloaded[src] = file
+		}
+		err := w.WriteAttachment(&archive.Attachment{URL: src, File: file, PostID: postID, CommentID: commentID})
+		if err != nil {
+			err = fmt.Errorf("cannot write attachment %s of post %d: %w", src, postID, err)
+			s.Logger.Printf("\tError: %v", err)
+			w.WriteError(topic, err)
+		}
+	}
+}
+
+func (s *Export) download(ctx context.Context, w *archive.Writer, src string) (string, error) {
+	u, err := url.Parse(src)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("unsupported url")
+	}
+	hash := sha1.Sum([]byte(src))
+	filePath, file := w.AttachmentPath(hex.EncodeToString(hash[:]) + path.Ext(u.Path))
+	f, err := os.Create(filePath)
+	if err != nil {
+		return "", err
+	}
+	err = s.ZClient.Download(ctx, src, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(filePath)
+		return "", err
+	}
+	return file, nil
+}
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr,
+			`Usage: zendesk-to-canny export \
+              -z-url zendesk_url -z-username zendesk_username (-z-password zendesk_userpassword | -z-token zendesk_api_token) \
+              -out dir zendesk_topic_id [zendesk_topic_id...]
+Exports Zendesk topics with posts, comments, votes, users and attachments to an archive directory.
+Options:
+`+zendeskUsage+`  --out dir                    Required. Directory to create archive in. It must not contain another archive
+  --parallel n                 Optional. Number of parallel loads from Zendesk. Default is 10
+  --no-attachments             Optional. Don't download images referenced by posts and comments
+`+httpUsage+`  --verbose                    Print verbose logging
+  --help                       Print usage
+Arguments:
+  Zendesk Help Center topic IDs to export (e.g. 115000153468-Integrations)
+`)
+	}
+	helpPtr := fs.Bool("help", false, "")
+	verbosePtr := fs.Bool("verbose", false, "")
+	zFlags := addZendeskFlags(fs)
+	outPtr := fs.String("out", "", "")
+	parallelPtr := fs.Int("parallel", 10, "")
+	noAttachmentsPtr := fs.Bool("no-attachments", false, "")
+	hFlags := addHTTPFlags(fs)
+	_ = fs.Parse(args)
+
+	if *helpPtr {
+		fs.Usage()
+		os.Exit(0)
+	}
+	if *outPtr == "" {
+		exitWithUsage(fs.Usage, "-out is required")
+	}
+	if fs.NArg() == 0 {
+		exitWithUsage(fs.Usage, "at least one zendesk_topic_id MUST be provided")
+	}
+	httpClient, err := hFlags.client()
+	if err != nil {
+		exitWithUsage(fs.Usage, "%v", err)
+	}
+	zClient, err := zFlags.client(httpClient, hFlags.replaying())
+	if err != nil {
+		exitWithUsage(fs.Usage, "%v", err)
+	}
+	zClient.LoadOrganizations = true
+	zClient.LoadContentTags = true
+	export := &Export{
+		ZClient:      zClient,
+		Topics:       fs.Args(),
+		Dir:          *outPtr,
+		ParallelLoad: *parallelPtr,
+		Attachments:  !*noAttachmentsPtr,
+		Verbose:      *verbosePtr,
+		Logger:       log.New(os.Stdout, "", 0),
+	}
+	ctx, cancel := interruptibleContext(export.Logger)
+	defer cancel()
+	if err := export.Export(ctx); err != nil {
+		_, _ = fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+}
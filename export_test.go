@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/Pleexy/zendesk-to-canny/archive"
+	"github.com/Pleexy/zendesk-to-canny/zendesk"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func countLines(t *testing.T, file string) int {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("cannot open %s: %v", file, err)
+	}
+	defer f.Close()
+	var n int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
+
+func TestExport(t *testing.T) {
+	env := newTestEnv(t)
+	env.zendesk.Topics[testTopic] = &zendesk.Topic{ID: 100, Name: "Ideas"}
+	env.zendesk.Files["/hc/user_images/dark.png"] = []byte("png")
+	env.zendesk.Lock()
+	env.zendesk.Posts[testTopic][0].Details += `<img src="` + env.zendesk.URL + `/hc/user_images/dark.png">`
+	env.zendesk.Unlock()
+
+	dir := filepath.Join(t.TempDir(), "archive")
+	export := &Export{
+		ZClient:      &zendesk.Client{BaseURL: env.zendesk.URL},
+		Topics:       []string{testTopic},
+		Dir:          dir,
+		ParallelLoad: 2,
+		Attachments:  true,
+		Logger:       log.New(ioutil.Discard, "", 0),
+	}
+	if err := export.Export(context.Background()); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatalf("manifest is not written: %v", err)
+	}
+	var manifest archive.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	if manifest.Version != archive.Version || len(manifest.Topics) != 1 {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+	for file, want := range map[string]int{
+		"topics.jsonl":      1,
+		"posts.jsonl":       3,
+		"comments.jsonl":    3,
+		"votes.jsonl":       3,
+		"users.jsonl":       3, // user 99 is missing in Zendesk
+		"attachments.jsonl": 1,
+	} {
+		if got := countLines(t, filepath.Join(dir, file)); got != want {
+			t.Errorf("%s has %d records, want %d", file, got, want)
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "attachments", "*.png"))
+	if len(files) != 1 {
+		t.Errorf("%d attachment files downloaded, want 1", len(files))
+	}
+}
+
+func TestExportFailure(t *testing.T) {
+	env := newTestEnv(t)
+	env.zendesk.Topics[testTopic] = &zendesk.Topic{ID: 100, Name: "Ideas"}
+	env.zendesk.Fail = func(r *http.Request) int {
+		if r.URL.Path == "/api/v2/community/topics/"+testTopic+"/posts.json" && r.URL.Query().Get("page[after]") != "" {
+			return 500
+		}
+		return 0
+	}
+	dir := filepath.Join(t.TempDir(), "archive")
+	export := &Export{
+		ZClient:      &zendesk.Client{BaseURL: env.zendesk.URL},
+		Topics:       []string{testTopic},
+		Dir:          dir,
+		ParallelLoad: 2,
+		Logger:       log.New(ioutil.Discard, "", 0),
+	}
+	if err := export.Export(context.Background()); err == nil {
+		t.Fatal("export must fail when posts cannot be listed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "manifest.json")); !os.IsNotExist(err) {
+		t.Errorf("manifest of an incomplete archive must not be written: %v", err)
+	}
+	if _, err := archive.Open(dir); err == nil {
+		t.Error("incomplete archive must not be opened")
+	}
+}
+
+func TestExportRecordsErrors(t *testing.T) {
+	env := newTestEnv(t)
+	env.zendesk.Topics[testTopic] = &zendesk.Topic{ID: 100, Name: "Ideas"}
+	env.zendesk.Lock()
+	env.zendesk.Posts[testTopic][1].Details += `<img src="` + env.zendesk.URL + `/hc/user_images/missing.png">`
+	env.zendesk.Unlock()
+	//votes of post 1 cannot be loaded, so the post is exported incomplete
+	env.zendesk.Fail = func(r *http.Request) int {
+		if r.URL.Path == "/api/v2/community/posts/1/votes.json" {
+			return 500
+		}
+		return 0
+	}
+	dir := filepath.Join(t.TempDir(), "archive")
+	export := &Export{
+		ZClient:      &zendesk.Client{BaseURL: env.zendesk.URL, RetryBackoff: time.Millisecond},
+		Topics:       []string{testTopic},
+		Dir:          dir,
+		ParallelLoad: 2,
+		Attachments:  true,
+		Logger:       log.New(ioutil.Discard, "", 0),
+	}
+	if err := export.Export(context.Background()); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	reader, err := archive.Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	var votes, downloads int
+	for _, exportErr := range reader.Manifest().Errors {
+		if strings.Contains(exportErr.Error, "votes of postID=1 are incomplete") {
+			votes++
+		}
+		if strings.Contains(exportErr.Error, "cannot download") {
+			downloads++
+		}
+	}
+	if votes != 1 || downloads != 1 {
+		t.Errorf("errors are not recorded in manifest: %+v", reader.Manifest().Errors)
+	}
+	var streamErrs []error
+	stream := reader.StreamPosts(context.Background(), testTopic, 1, func(err error) {
+		streamErrs = append(streamErrs, err)
+	})
+	for range stream.Posts {
+	}
+	if err := stream.Err(); err != nil || len(streamErrs) != len(reader.Manifest().Errors) {
+		t.Errorf("export errors are not reported by the archive: %v %v", err, streamErrs)
+	}
+}
+
+func TestMigrateFromArchive(t *testing.T) {
+	env := newTestEnv(t)
+	env.zendesk.Topics[testTopic] = &zendesk.Topic{ID: 100, Name: "Ideas"}
+	dir := filepath.Join(t.TempDir(), "archive")
+	export := &Export{
+		ZClient:      &zendesk.Client{BaseURL: env.zendesk.URL},
+		Topics:       []string{testTopic},
+		Dir:          dir,
+		ParallelLoad: 2,
+		Logger:       log.New(ioutil.Discard, "", 0),
+	}
+	if err := export.Export(context.Background()); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	env.zendesk.Close()
+
+	source, err := archive.Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	m := env.migration()
+	m.Source = source
+	m.DefaultUserID = env.adminID
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	env.assertCounts(t, 3, 3, 3)
+	post := env.canny.PostByTitle("Dark mode")
+	if post == nil || len(env.canny.PostComments(post.ID)) != 3 || len(env.canny.PostVotes(post.ID)) != 2 {
+		t.Errorf("post 'Dark mode' is not migrated with its comments and votes")
+	}
+}
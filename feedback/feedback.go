@@ -0,0 +1,212 @@
+//Package feedback defines source-neutral posts, comments, votes and users, and a Source interface,
+//so posts from Zendesk or any other feedback tool can be migrated to Canny the same way
+package feedback
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+//Roles of users
+const (
+	RoleEndUser = "end-user"
+	RoleAgent   = "agent"
+	RoleAdmin   = "admin"
+)
+
+//User is an author of a post or comment, or a voter
+type User struct {
+	ID             string                 `json:"id"`
+	Name           string                 `json:"name"`
+	Email          string                 `json:"email,omitempty"`
+	ExternalID     string                 `json:"external_id,omitempty"`
+	CreatedAt      time.Time              `json:"created_at,omitempty"`
+	Role           string                 `json:"role,omitempty"` // RoleEndUser, RoleAgent or RoleAdmin. Empty means end-user
+	AvatarURL      string                 `json:"avatar_url,omitempty"`
+	OrganizationID string                 `json:"organization_id,omitempty"`
+	Locale         string                 `json:"locale,omitempty"`
+	TimeZone       string                 `json:"time_zone,omitempty"`
+	Fields         map[string]interface{} `json:"fields,omitempty"` // custom user fields of the source
+	Organization   *Organization          `json:"organization,omitempty"`
+}
+
+//Organization is a company of a user
+type Organization struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	CreatedAt time.Time              `json:"created_at,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"` // custom organization fields of the source
+}
+
+//IsStaff reports whether the user is an agent or admin
+func (u *User) IsStaff() bool {
+	return u.Role == RoleAgent || u.Role == RoleAdmin
+}
+
+//Field returns a value of a standard field (role, locale, time_zone, organization_id) or a custom field of the user.
+//It returns nil if the field is not set
+func (u *User) Field(name string) interface{} {
+	var value string
+	switch name {
+	case "role":
+		value = u.Role
+	case "locale":
+		value = u.Locale
+	case "time_zone":
+		value = u.TimeZone
+	case "organization_id":
+		value = u.OrganizationID
+	default:
+		return u.Fields[name]
+	}
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+//Post is a post (idea, feature request) with its comments and votes
+type Post struct {
+	ID        string    `json:"id"`
+	Topic     string    `json:"topic"`
+	TopicName string    `json:"topic_name,omitempty"`
+	Title     string    `json:"title"`
+	Details   string    `json:"details"` // HTML or plain text
+	URL       string    `json:"url,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Tags      []string  `json:"tags,omitempty"` // names of tags, e.g. Zendesk content tags
+	Featured  bool      `json:"featured,omitempty"`
+	Pinned    bool      `json:"pinned,omitempty"`
+	VoteCount int       `json:"vote_count,omitempty"` // number of votes reported by the source
+	VoteSum   int       `json:"vote_sum,omitempty"`   // sum of up and down votes reported by the source
+	//CommentsIncomplete and VotesIncomplete are set if the source failed to load all comments or votes
+	CommentsIncomplete bool       `json:"comments_incomplete,omitempty"`
+	VotesIncomplete    bool       `json:"votes_incomplete,omitempty"`
+	Author             *User      `json:"author"`
+	Comments           []*Comment `json:"comments,omitempty"`
+	Votes              []*Vote    `json:"votes,omitempty"`
+}
+
+//Field returns a value of a post field: id, url, topic, topic_name, created_at (RFC 3339), vote_count or vote_sum.
+//It returns nil for unknown or empty fields
+func (p *Post) Field(name string) interface{} {
+	switch name {
+	case "id":
+		return nonEmpty(p.ID)
+	case "url":
+		return nonEmpty(p.URL)
+	case "topic":
+		return nonEmpty(p.Topic)
+	case "topic_name":
+		return nonEmpty(p.TopicName)
+	case "created_at":
+		if p.CreatedAt.IsZero() {
+			return nil
+		}
+		return p.CreatedAt.UTC().Format(time.RFC3339)
+	case "vote_count":
+		return p.VoteCount
+	case "vote_sum":
+		return p.VoteSum
+	}
+	return nil
+}
+
+func nonEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+//Comment is a comment of a post
+type Comment struct {
+	ID        string    `json:"id"`
+	Body      string    `json:"body"` // HTML or plain text
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Author    *User     `json:"author"`
+}
+
+//Vote is a vote for a post
+type Vote struct {
+	ID    string `json:"id"`
+	Value int    `json:"value,omitempty"` // negative for downvotes, 0 or positive for upvotes
+	User  *User  `json:"user"`
+}
+
+//IsDown reports whether the vote is a downvote
+func (v *Vote) IsDown() bool {
+	return v.Value < 0
+}
+
+//SkippedError is reported to ErrorCallback for a post which is filtered out by the source
+type SkippedError struct {
+	ID     string
+	Title  string
+	Reason string
+}
+
+func (e *SkippedError) Error() string {
+	return fmt.Sprintf("post '%s' (%s) is filtered out: %s", e.Title, e.ID, e.Reason)
+}
+
+//ErrorCallback is called for errors that don't stop loading of posts, e.g. a post which comments cannot be loaded
+type ErrorCallback func(err error)
+
+//Source provides posts of a topic (forum, category, board) with comments, votes and users resolved.
+//Nil Author or User means that the user is unknown, e.g. deleted
+type Source interface {
+	Posts(ctx context.Context, topic string, errCB ErrorCallback) *Stream
+}
+
+//PostLoader is implemented by sources which can load a single post, to retry posts with incomplete comments or votes.
+//Only the parts of the post are loaded, other comments or votes are empty
+type PostLoader interface {
+	Post(ctx context.Context, topic, id string, parts PostParts) (*Post, error)
+}
+
+//PostParts selects what is loaded with a post by PostLoader
+type PostParts struct {
+	Comments bool
+	Votes    bool
+}
+
+//Incomplete reports whether comments or votes of the post are not loaded completely
+func (p *Post) Incomplete() bool {
+	return p.CommentsIncomplete || p.VotesIncomplete
+}
+
+//Stream is a stream of posts
+type Stream struct {
+	Posts <-chan *Post
+	done  chan struct{}
+	err   error
+}
+
+//NewStream creates a stream of posts produced by load. load is called in a separate goroutine,
+//it must send posts to the channel until it is done or ctx is cancelled and return a fatal error, if any
+func NewStream(load func(posts chan<- *Post) error) *Stream {
+	posts := make(chan *Post)
+	stream := &Stream{
+		Posts: posts,
+		done:  make(chan struct{}),
+	}
+	go func() {
+		stream.err = load(posts)
+		close(posts)
+		close(stream.done)
+	}()
+	return stream
+}
+
+//Err waits until the stream is finished and returns a fatal error that stopped loading of the topic, if any
+func (s *Stream) Err() error {
+	<-s.done
+	return s.err
+}
+
+//UserCache is implemented by sources that can reuse users known from a previous run instead of loading them again
+type UserCache interface {
+	AddUsers(users []*User)
+}
@@ -0,0 +1,214 @@
+package feedback
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//FileSource reads posts from a JSON or CSV file, e.g. converted from GitHub Discussions or UserVoice export.
+//
+//JSON (.json) file is an array of Post objects, each with nested author, comments and votes:
+//
+//	[{"id": "1", "topic": "ideas", "title": "Dark mode", "details": "<p>Please</p>",
+//	  "url": "https://forum/1", "created_at": "2019-05-01T10:00:00Z",
+//	  "author": {"id": "u1", "name": "Ann", "email": "ann@example.com", "external_id": "", "created_at": "2018-01-01T00:00:00Z"},
+//	  "comments": [{"id": "c1", "body": "Yes", "created_at": "2019-05-02T10:00:00Z", "author": {"id": "u2", "name": "Bob"}}],
+//	  "votes": [{"id": "v1", "user": {"id": "u2", "name": "Bob"}}]}]
+//
+//Posts are read one by one, so the file can be large. A user can be specified in full once and referenced by id only afterwards.
+//
+//CSV (.csv) file has a header row and one row per post, comment or vote with columns:
+//
+//	type        - post, comment or vote
+//	id          - ID of the post, comment or vote, unique per type
+//	topic       - topic of a post
+//	post_id     - post ID of a comment or vote
+//	title       - title of a post
+//	body        - details of a post or body of a comment
+//	url         - original URL of a post
+//	created_at  - RFC 3339 time of a post or comment
+//	user_id     - ID of an author or a voter
+//	user_name   - name of an author or a voter
+//	user_email  - email of an author or a voter
+//
+//Columns may be in any order, missing columns are empty. CSV file is loaded into memory
+type FileSource struct {
+	Path string
+}
+
+//Posts implements Source
+func (s *FileSource) Posts(ctx context.Context, topic string, errCB ErrorCallback) *Stream {
+	return NewStream(func(posts chan<- *Post) error {
+		f, err := os.Open(s.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		users := make(map[string]*User)
+		send := func(post *Post) error {
+			if post.Topic != topic {
+				return nil
+			}
+			select {
+			case posts <- post:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		switch strings.ToLower(filepath.Ext(s.Path)) {
+		case ".json":
+			return readJSONPosts(f, users, send)
+		case ".csv":
+			return readCSVPosts(f, users, send, errCB)
+		default:
+			return fmt.Errorf("unsupported file %s, .json or .csv is expected", s.Path)
+		}
+	})
+}
+
+func readJSONPosts(r io.Reader, users map[string]*User, send func(post *Post) error) error {
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("invalid JSON, array of posts is expected: %w", err)
+	}
+	for dec.More() {
+		var post Post
+		if err := dec.Decode(&post); err != nil {
+			return fmt.Errorf("invalid post: %w", err)
+		}
+		post.Author = resolveUser(users, post.Author)
+		for _, comment := range post.Comments {
+			comment.Author = resolveUser(users, comment.Author)
+		}
+		for _, vote := range post.Votes {
+			vote.User = resolveUser(users, vote.User)
+		}
+		if err := send(&post); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//resolveUser returns the full user for a user that is referenced by id only
+func resolveUser(users map[string]*User, user *User) *User {
+	if user == nil || user.ID == "" {
+		return user
+	}
+	if known, ok := users[user.ID]; ok && user.Name == "" && user.Email == "" {
+		return known
+	}
+	users[user.ID] = user
+	return user
+}
+
+func readCSVPosts(r io.Reader, users map[string]*User, send func(post *Post) error, errCB ErrorCallback) error {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	byID := make(map[string]*Post)
+	ordered := make([]*Post, 0)
+	children := make([]csvRow, 0)
+	for _, cells := range rows[1:] {
+		row := csvRow{columns: columns, cells: cells}
+		value := row.get
+		if value("type") != "post" {
+			children = append(children, row)
+			continue
+		}
+		post := &Post{
+			ID:      value("id"),
+			Topic:   value("topic"),
+			Title:   value("title"),
+			Details: value("body"),
+			URL:     value("url"),
+			Author:  csvUser(users, value),
+		}
+		post.CreatedAt, err = parseCSVTime(value("created_at"))
+		if err != nil {
+			return fmt.Errorf("invalid created_at of post %s: %w", post.ID, err)
+		}
+		byID[post.ID] = post
+		ordered = append(ordered, post)
+	}
+	for _, row := range children {
+		value := row.get
+		post := byID[value("post_id")]
+		if post == nil {
+			if errCB != nil {
+				errCB(fmt.Errorf("%s %s refers to unknown post %s", value("type"), value("id"), value("post_id")))
+			}
+			continue
+		}
+		switch value("type") {
+		case "comment":
+			createdAt, err := parseCSVTime(value("created_at"))
+			if err != nil {
+				return fmt.Errorf("invalid created_at of comment %s: %w", value("id"), err)
+			}
+			post.Comments = append(post.Comments, &Comment{
+				ID:        value("id"),
+				Body:      value("body"),
+				CreatedAt: createdAt,
+				Author:    csvUser(users, value),
+			})
+		case "vote":
+			post.Votes = append(post.Votes, &Vote{ID: value("id"), User: csvUser(users, value)})
+		default:
+			return fmt.Errorf("unknown type %s", value("type"))
+		}
+	}
+	for _, post := range ordered {
+		if err := send(post); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//csvRow is a row of CSV file with access to cells by column name
+type csvRow struct {
+	columns map[string]int
+	cells   []string
+}
+
+func (r csvRow) get(name string) string {
+	if i, ok := r.columns[name]; ok && i < len(r.cells) {
+		return strings.TrimSpace(r.cells[i])
+	}
+	return ""
+}
+
+func csvUser(users map[string]*User, value func(name string) string) *User {
+	if value("user_id") == "" {
+		return nil
+	}
+	return resolveUser(users, &User{
+		ID:    value("user_id"),
+		Name:  value("user_name"),
+		Email: value("user_email"),
+	})
+}
+
+func parseCSVTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,68 @@
+package feedback
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func readPosts(t *testing.T, name, content, topic string) []*Post {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stream := (&FileSource{Path: path}).Posts(context.Background(), topic, func(err error) {
+		t.Errorf("unexpected error: %v", err)
+	})
+	posts := make([]*Post, 0)
+	for post := range stream.Posts {
+		posts = append(posts, post)
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	return posts
+}
+
+func assertPost(t *testing.T, posts []*Post) {
+	t.Helper()
+	if len(posts) != 1 {
+		t.Fatalf("got %d posts, want 1", len(posts))
+	}
+	post := posts[0]
+	if post.ID != "1" || post.Title != "Dark mode" || post.Author == nil || post.Author.Email != "ann@example.com" {
+		t.Errorf("unexpected post %+v", post)
+	}
+	if len(post.Comments) != 1 || post.Comments[0].Author == nil || post.Comments[0].Author.Name != "Bob" {
+		t.Errorf("unexpected comments %+v", post.Comments)
+	}
+	if len(post.Votes) != 1 || post.Votes[0].User == nil || post.Votes[0].User.Name != "Bob" {
+		t.Errorf("unexpected votes %+v", post.Votes)
+	}
+}
+
+func TestFileSourceJSON(t *testing.T) {
+	posts := readPosts(t, "posts.json", `[
+ {"id": "1", "topic": "ideas", "title": "Dark mode", "details": "Please",
+  "author": {"id": "u1", "name": "Ann", "email": "ann@example.com"},
+  "comments": [{"id": "c1", "body": "Yes", "author": {"id": "u2", "name": "Bob"}}],
+  "votes": [{"id": "v1", "user": {"id": "u2"}}]},
+ {"id": "2", "topic": "bugs", "title": "Crash", "author": {"id": "u1"}}
+]`, "ideas")
+	assertPost(t, posts)
+}
+
+func TestFileSourceCSV(t *testing.T) {
+	posts := readPosts(t, "posts.csv", `type,id,topic,post_id,title,body,created_at,user_id,user_name,user_email
+comment,c1,,1,,Yes,2019-05-02T10:00:00Z,u2,Bob,
+post,1,ideas,,Dark mode,Please,2019-05-01T10:00:00Z,u1,Ann,ann@example.com
+vote,v1,,1,,,,u2,,
+post,2,bugs,,Crash,,,u1,,
+`, "ideas")
+	assertPost(t, posts)
+	if posts[0].CreatedAt.IsZero() {
+		t.Error("created_at is not parsed")
+	}
+}
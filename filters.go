@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/zendesk"
+	flag "github.com/spf13/pflag"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const filterUsage = `  --since date                 Optional. Skip posts created before the date, RFC 3339 or YYYY-MM-DD
+  --until date                 Optional. Skip posts created at or after the date, RFC 3339 or YYYY-MM-DD
+  --filter-date field          Optional. Post date --since and --until are applied to: 'created' (default) or 'updated'
+  --min-votes n                Optional. Skip posts with less votes
+  --min-comments n             Optional. Skip posts with less comments
+  --status statuses            Optional. Comma separated list of Zendesk post statuses to migrate, e.g. planned,completed.
+                                         Statuses are none, planned, not_planned, answered and completed
+  --exclude-status statuses    Optional. Comma separated list of Zendesk post statuses to skip
+  --exclude-closed             Optional. Skip posts closed for comments
+  --title-match regexp         Optional. Migrate only posts with titles matching the regular expression
+  --title-exclude regexp       Optional. Skip posts with titles matching the regular expression
+  --post-ids file              Optional. Migrate only posts with IDs listed in the file, one per line
+  --exclude-post-ids file      Optional. Skip posts with IDs listed in the file, one per line
+`
+
+//filterFlags contains command line options to select Zendesk posts to migrate
+type filterFlags struct {
+	since          *string
+	until          *string
+	dateField      *string
+	minVotes       *int
+	minComments    *int
+	statuses       *[]string
+	excludeStatus  *[]string
+	excludeClosed  *bool
+	titleMatch     *string
+	titleExclude   *string
+	postIDs        *string
+	excludePostIDs *string
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	return &filterFlags{
+		since:          fs.String("since", "", ""),
+		until:          fs.String("until", "", ""),
+		dateField:      fs.String("filter-date", "created", ""),
+		minVotes:       fs.Int("min-votes", 0, ""),
+		minComments:    fs.Int("min-comments", 0, ""),
+		statuses:       fs.StringSlice("status", []string{}, ""),
+		excludeStatus:  fs.StringSlice("exclude-status", []string{}, ""),
+		excludeClosed:  fs.Bool("exclude-closed", false, ""),
+		titleMatch:     fs.String("title-match", "", ""),
+		titleExclude:   fs.String("title-exclude", "", ""),
+		postIDs:        fs.String("post-ids", "", ""),
+		excludePostIDs: fs.String("exclude-post-ids", "", ""),
+	}
+}
+
+//filter validates options and creates Zendesk posts filter, nil if no option is set
+func (f *filterFlags) filter() (*zendesk.Filter, error) {
+	filter := &zendesk.Filter{
+		MinVotes:        *f.minVotes,
+		MinComments:     *f.minComments,
+		Statuses:        *f.statuses,
+		ExcludeStatuses: *f.excludeStatus,
+		ExcludeClosed:   *f.excludeClosed,
+	}
+	var err error
+	switch *f.dateField {
+	case "created":
+	case "updated":
+		filter.ByUpdated = true
+	default:
+		return nil, fmt.Errorf("invalid -filter-date %s, allowed values are created and updated", *f.dateField)
+	}
+	if filter.Since, err = parseDate("since", *f.since); err != nil {
+		return nil, err
+	}
+	if filter.Until, err = parseDate("until", *f.until); err != nil {
+		return nil, err
+	}
+	if filter.TitleMatch, err = parseRegexp("title-match", *f.titleMatch); err != nil {
+		return nil, err
+	}
+	if filter.TitleExclude, err = parseRegexp("title-exclude", *f.titleExclude); err != nil {
+		return nil, err
+	}
+	if filter.IDs, err = readPostIDs(*f.postIDs); err != nil {
+		return nil, err
+	}
+	if filter.ExcludeIDs, err = readPostIDs(*f.excludePostIDs); err != nil {
+		return nil, err
+	}
+	if emptyFilter(filter) {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+//emptyFilter reports whether the filter doesn't skip any post
+func emptyFilter(f *zendesk.Filter) bool {
+	return f.Since.IsZero() && f.Until.IsZero() && f.MinVotes == 0 && f.MinComments == 0 &&
+		len(f.Statuses) == 0 && len(f.ExcludeStatuses) == 0 && !f.ExcludeClosed &&
+		f.TitleMatch == nil && f.TitleExclude == nil && f.IDs == nil && f.ExcludeIDs == nil
+}
+
+func parseDate(flagName, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -%s %s, use RFC 3339 or YYYY-MM-DD", flagName, value)
+	}
+	return date, nil
+}
+
+func parseRegexp(flagName, value string) (*regexp.Regexp, error) {
+	if value == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -%s:%w", flagName, err)
+	}
+	return re, nil
+}
+
+//readPostIDs reads post IDs from the file, one per line. Empty lines and lines starting with # are ignored.
+//It returns nil if path is empty
+func readPostIDs(path string) (map[int64]bool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open post IDs file:%w", err)
+	}
+	defer file.Close()
+	ids := make(map[int64]bool)
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid post ID '%s' at line %d of %s", line, n, path)
+		}
+		ids[id] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read post IDs file:%w", err)
+	}
+	return ids, nil
+}
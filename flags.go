@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/cassette"
+	"github.com/Pleexy/zendesk-to-canny/httpclient"
+	"github.com/Pleexy/zendesk-to-canny/zendesk"
+	flag "github.com/spf13/pflag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const zendeskUsage = `  --z-url url                  Required. Zendesk URL (e.g. https://your_company.zendesk.com) 
+  --z-username username        Required, unless --z-oauth-token is used. User name (email) to access Zendesk API
+  --z-password pass            User password to access Zendesk API. Password authentication must be enabled in Zendesk.
+  --z-token token              Zendesk API token. Used with --z-username instead of --z-password.
+  --z-oauth-token token        Zendesk OAuth access token. Used instead of --z-username and --z-password/--z-token.
+`
+
+const httpUsage = `  --timeout duration           Optional. Timeout of a request to Zendesk or Canny including its response, e.g. 30s. Default is 60s.
+                                         Downloads of attachments fail only if no data is received for the timeout
+  --connect-timeout duration   Optional. Timeout to establish a connection to Zendesk or Canny. Default is 10s
+  --max-idle-conns n           Optional. Max number of idle connections kept open per host. Default is 10
+  --proxy url                  Optional. HTTP proxy URL, e.g. http://proxy.local:3128. By default HTTPS_PROXY/HTTP_PROXY environment variables are used
+  --ca-bundle file             Optional. PEM file with additional CA certificates to trust, e.g. for a corporate TLS proxy
+  --record dir                 Optional. Save every Zendesk and Canny HTTP request and response to dir, with credentials removed
+  --replay dir                 Optional. Serve Zendesk and Canny responses from dir saved by --record instead of calling the APIs.
+                                         Credentials are not required. Use a copy of the state file the recorded run started with.
+                                         Requests are matched by path, query and body, so --z-url and --c-url may differ from the recorded ones.
+`
+
+//zendeskFlags contains command line options to access Zendesk API
+type zendeskFlags struct {
+	url        *string
+	username   *string
+	password   *string
+	token      *string
+	oauthToken *string
+}
+
+func addZendeskFlags(fs *flag.FlagSet) *zendeskFlags {
+	return &zendeskFlags{
+		url:        fs.String("z-url", "", ""),
+		username:   fs.String("z-username", "", ""),
+		password:   fs.String("z-password", "", ""),
+		token:      fs.String("z-token", "", ""),
+		oauthToken: fs.String("z-oauth-token", "", ""),
+	}
+}
+
+//client validates options and creates Zendesk client. Credentials are not required if requests are replayed
+func (f *zendeskFlags) client(httpClient *http.Client, replay bool) (*zendesk.Client, error) {
+	if *f.url == "" {
+		return nil, errors.New("-z-url is required")
+	}
+	var auth zendesk.Authenticator
+	if !replay {
+		var err error
+		auth, err = zendeskAuth(*f.username, *f.password, *f.token, *f.oauthToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &zendesk.Client{
+		Auth:       auth,
+		BaseURL:    *f.url,
+		HTTPClient: httpClient,
+	}, nil
+}
+
+//zendeskAuth selects Zendesk authentication from the provided credentials
+func zendeskAuth(username, password, token, oauthToken string) (zendesk.Authenticator, error) {
+	switch {
+	case oauthToken != "":
+		if username != "" || password != "" || token != "" {
+			return nil, errors.New("-z-oauth-token cannot be combined with -z-username, -z-password or -z-token")
+		}
+		return zendesk.BearerAuth{Token: oauthToken}, nil
+	case username == "":
+		return nil, errors.New("-z-username with -z-password or -z-token, or -z-oauth-token is required")
+	case token != "" && password != "":
+		return nil, errors.New("only one of -z-password and -z-token can be provided")
+	case token != "":
+		return zendesk.TokenAuth{Email: username, Token: token}, nil
+	case password != "":
+		return zendesk.PasswordAuth{Username: username, Password: password}, nil
+	default:
+		return nil, errors.New("-z-password or -z-token is required with -z-username")
+	}
+}
+
+//httpFlags contains command line options of HTTP client shared by Zendesk and Canny clients
+type httpFlags struct {
+	timeout        *time.Duration
+	connectTimeout *time.Duration
+	maxIdleConns   *int
+	proxy          *string
+	caBundle       *string
+	record         *string
+	replay         *string
+}
+
+func addHTTPFlags(fs *flag.FlagSet) *httpFlags {
+	return &httpFlags{
+		timeout:        fs.Duration("timeout", 0, ""),
+		connectTimeout: fs.Duration("connect-timeout", 0, ""),
+		maxIdleConns:   fs.Int("max-idle-conns", 0, ""),
+		proxy:          fs.String("proxy", "", ""),
+		caBundle:       fs.String("ca-bundle", "", ""),
+		record:         fs.String("record", "", ""),
+		replay:         fs.String("replay", "", ""),
+	}
+}
+
+func (f *httpFlags) replaying() bool {
+	return *f.replay != ""
+}
+
+//client validates options and creates http.Client
+func (f *httpFlags) client() (*http.Client, error) {
+	if *f.record != "" && *f.replay != "" {
+		return nil, errors.New("-record and -replay cannot be used together")
+	}
+	if *f.replay != "" {
+		replayer, err := cassette.NewReplayer(*f.replay)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Client{Transport: replayer}, nil
+	}
+	httpClient, err := httpclient.New(httpclient.Options{
+		ConnectTimeout: *f.connectTimeout,
+		Timeout:        *f.timeout,
+		MaxIdleConns:   *f.maxIdleConns,
+		ProxyURL:       *f.proxy,
+		CABundle:       *f.caBundle,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if *f.record != "" {
+		httpClient.Transport = &cassette.Recorder{Dir: *f.record, Transport: httpClient.Transport}
+	}
+	return httpClient, nil
+}
+
+//interruptibleContext returns a context that is cancelled on SIGINT or SIGTERM
+func interruptibleContext(logger *log.Logger) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			logger.Print("Interrupted, saving state...")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}
+
+//exitWithUsage prints error and usage and exits
+func exitWithUsage(usage func(), format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(os.Stderr, format+"\n", args...)
+	usage()
+	os.Exit(1)
+}
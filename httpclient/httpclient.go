@@ -0,0 +1,151 @@
+//Package httpclient builds http.Client used to access Zendesk and Canny APIs
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+//Options contains settings of http.Client. Zero values are replaced with defaults
+type Options struct {
+	ConnectTimeout time.Duration // timeout to establish a TCP connection, default 10s
+	Timeout        time.Duration // timeout of a request including reading of the response, default 60s. See Download
+	MaxIdleConns   int           // max idle (keep-alive) connections per host, default 10
+	ProxyURL       string        // HTTP(S) proxy. If empty, HTTP_PROXY/HTTPS_PROXY environment variables are used
+	CABundle       string        // path to a PEM file with additional trusted CA certificates
+}
+
+const (
+	defaultConnectTimeout = 10 * time.Second
+	defaultTimeout        = 60 * time.Second
+	defaultMaxIdleConns   = 10
+)
+
+//Default is a client with default options. It is used by API clients that have no http.Client set
+var Default = mustNew(Options{})
+
+//New creates a http.Client with specified options
+func New(opts Options) (*http.Client, error) {
+	if opts.ConnectTimeout == 0 {
+		opts.ConnectTimeout = defaultConnectTimeout
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxIdleConns == 0 {
+		opts.MaxIdleConns = defaultMaxIdleConns
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   opts.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          opts.MaxIdleConns * 2,
+		MaxIdleConnsPerHost:   opts.MaxIdleConns,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   opts.ConnectTimeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if opts.ProxyURL != "" {
+		proxyURL, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url %s: %w", opts.ProxyURL, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if opts.CABundle != "" {
+		pem, err := ioutil.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA bundle:%w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &http.Client{Transport: transport, Timeout: opts.Timeout}, nil
+}
+
+//Download sends the request with the client for a long download, e.g. of an attachment. The client timeout doesn't
+//limit the whole download, the request is canceled if no data is received for the timeout instead
+func Download(client *http.Client, req *http.Request) (*http.Response, error) {
+	timeout := client.Timeout
+	noTimeout := *client
+	noTimeout.Timeout = 0
+	if timeout == 0 {
+		return noTimeout.Do(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	body := &idleReader{timeout: timeout, cancel: cancel}
+	body.timer = time.AfterFunc(timeout, body.stall)
+	resp, err := noTimeout.Do(req.WithContext(ctx))
+	if err != nil {
+		body.Close()
+		return nil, body.wrap(err)
+	}
+	body.ReadCloser = resp.Body
+	resp.Body = body
+	return resp, nil
+}
+
+//idleReader is a response body which cancels the request if no data is read for the timeout
+type idleReader struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	stalled int32 // set to 1 if the request is canceled by timer
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, r.wrap(err)
+}
+
+func (r *idleReader) Close() error {
+	r.timer.Stop()
+	r.cancel()
+	if r.ReadCloser == nil {
+		return nil
+	}
+	return r.ReadCloser.Close()
+}
+
+func (r *idleReader) stall() {
+	atomic.StoreInt32(&r.stalled, 1)
+	r.cancel()
+}
+
+//wrap replaces an error of the canceled request with a timeout error
+func (r *idleReader) wrap(err error) error {
+	if err != nil && err != io.EOF && atomic.LoadInt32(&r.stalled) == 1 {
+		return fmt.Errorf("no data is received for %v:%w", r.timeout, err)
+	}
+	return err
+}
+
+func mustNew(opts Options) *http.Client {
+	cli, err := New(opts)
+	if err != nil {
+		panic(err)
+	}
+	return cli
+}
@@ -0,0 +1,131 @@
+package httpclient_test
+
+import (
+	"encoding/pem"
+	"github.com/Pleexy/zendesk-to-canny/httpclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-headers" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		switch r.URL.Path {
+		case "/slow-body":
+			//the body takes longer than the timeout, e.g. a large download
+			for i := 0; i < 4; i++ {
+				time.Sleep(50 * time.Millisecond)
+				_, _ = w.Write([]byte("chunk"))
+				w.(http.Flusher).Flush()
+			}
+		case "/stalled-body":
+			_, _ = w.Write([]byte("chunk"))
+			w.(http.Flusher).Flush()
+			time.Sleep(300 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	client, err := httpclient.New(httpclient.Options{Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := client.Get(server.URL + "/slow-headers"); err == nil {
+		t.Error("request waiting for headers longer than timeout must fail")
+	}
+	if _, err := get(client, server.URL+"/stalled-body", false); err == nil {
+		t.Error("request stalled after headers must fail")
+	}
+	body, err := get(client, server.URL+"/slow-body", true)
+	if err != nil || len(body) != 20 {
+		t.Errorf("slow download must be read completely, got %d bytes: %v", len(body), err)
+	}
+	if _, err := get(client, server.URL+"/stalled-body", true); err == nil {
+		t.Error("download stalled longer than timeout must fail")
+	}
+}
+
+//get reads the body of url with client, or with httpclient.Download if download is set
+func get(client *http.Client, url string, download bool) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp *http.Response
+	if download {
+		resp, err = httpclient.Download(client, req)
+	} else {
+		resp, err = client.Do(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+func TestProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+	client, err := httpclient.New(httpclient.Options{ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	resp, err := client.Get("http://zendesk.invalid/api/v2/users/me.json")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+	if proxied != "http://zendesk.invalid/api/v2/users/me.json" {
+		t.Errorf("request is not sent through the proxy, proxy got '%s'", proxied)
+	}
+	if _, err := httpclient.New(httpclient.Options{ProxyURL: "://bad"}); err == nil {
+		t.Error("invalid proxy url must be rejected")
+	}
+}
+
+func TestCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(bundle, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := httpclient.New(httpclient.Options{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Error("server certificate must not be trusted without the CA bundle")
+	}
+	client, err = httpclient.New(httpclient.Options{CABundle: bundle})
+	if err != nil {
+		t.Fatalf("New with CA bundle: %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get with CA bundle: %v", err)
+	}
+	resp.Body.Close()
+
+	empty := filepath.Join(dir, "empty.pem")
+	if err := ioutil.WriteFile(empty, []byte("no certificates"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := httpclient.New(httpclient.Options{CABundle: empty}); err == nil {
+		t.Error("CA bundle without certificates must be rejected")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/destination"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
+	"strings"
+)
+
+//Sources of post tags and categories
+const (
+	LabelTopic       = "topic"        // name of the source topic
+	LabelContentTags = "content-tags" // source tags of the post, e.g. Zendesk content tags
+	LabelContentTag  = "content-tag"  // the first source tag of the post, for a category
+	LabelFlags       = "flags"        // 'featured' and 'pinned' for featured and pinned posts
+)
+
+const (
+	kindTag      = "tag"
+	kindCategory = "category"
+)
+
+//PostLabels defines how posts are tagged and categorized in Canny. Missing tags and categories are created
+type PostLabels struct {
+	TagTopic       bool
+	TagContentTags bool
+	TagFlags       bool
+	//CategoryBy is LabelTopic, LabelContentTag or empty to not categorize posts
+	CategoryBy string
+}
+
+//ParsePostLabels parses comma separated tag sources (topic, content-tags, flags) and a category source (topic, content-tag)
+func ParsePostLabels(tagBy []string, categoryBy string) (PostLabels, error) {
+	var labels PostLabels
+	for _, source := range tagBy {
+		switch source {
+		case LabelTopic:
+			labels.TagTopic = true
+		case LabelContentTags:
+			labels.TagContentTags = true
+		case LabelFlags:
+			labels.TagFlags = true
+		default:
+			return labels, fmt.Errorf("invalid tag-by %s, expected topic, content-tags or flags", source)
+		}
+	}
+	switch categoryBy {
+	case "", LabelTopic, LabelContentTag:
+		labels.CategoryBy = categoryBy
+	default:
+		return labels, fmt.Errorf("invalid category-by %s, expected topic or content-tag", categoryBy)
+	}
+	return labels, nil
+}
+
+//enabled reports whether posts are tagged or categorized
+func (l *PostLabels) enabled() bool {
+	return l.TagTopic || l.TagContentTags || l.TagFlags || l.CategoryBy != ""
+}
+
+//tags returns names of tags of the post
+func (l *PostLabels) tags(post *feedback.Post) []string {
+	tags := make([]string, 0)
+	if l.TagTopic {
+		tags = append(tags, topicName(post))
+	}
+	if l.TagContentTags {
+		tags = append(tags, post.Tags...)
+	}
+	if l.TagFlags && post.Featured {
+		tags = append(tags, "featured")
+	}
+	if l.TagFlags && post.Pinned {
+		tags = append(tags, "pinned")
+	}
+	return tags
+}
+
+//category returns name of the post category, or empty string
+func (l *PostLabels) category(post *feedback.Post) string {
+	switch l.CategoryBy {
+	case LabelTopic:
+		return topicName(post)
+	case LabelContentTag:
+		if len(post.Tags) > 0 {
+			return post.Tags[0]
+		}
+	}
+	return ""
+}
+
+func topicName(post *feedback.Post) string {
+	if post.TopicName != "" {
+		return post.TopicName
+	}
+	return post.Topic
+}
+
+//tagPost adds tags to a created post. Added tags are saved to state, so they are not added again
+func (s *Migration) tagPost(ctx context.Context, post *feedback.Post, topic, cBoard, postID string) error {
+	for _, name := range s.Labels.tags(post) {
+		name = strings.TrimSpace(name)
+		key := post.ID + "_" + name
+		if name == "" || s.getIDFromState(topic, "post_tag", key) != "" {
+			continue
+		}
+		tagID, err := s.labelID(ctx, kindTag, cBoard, name)
+		if err != nil {
+			return err
+		}
+		if err := s.organizer.AddTag(ctx, postID, tagID); err != nil {
+			return fmt.Errorf("cannot add tag '%s':%w", name, err)
+		}
+		s.saveIDToState(topic, "post_tag", key, tagID)
+	}
+	return nil
+}
+
+//labelID returns ID of the tag or category of the board, creating it if it doesn't exist.
+//Existing tags and categories of a board are listed once and saved to state
+func (s *Migration) labelID(ctx context.Context, kind, cBoard, name string) (string, error) {
+	if id := s.state.getLabel(kind, cBoard, name); id != "" {
+		return id, nil
+	}
+	listKey := formatKey(kind, cBoard)
+	if !s.listedLabels[listKey] {
+		var labels []destination.Label
+		var err error
+		if kind == kindTag {
+			labels, err = s.organizer.ListTags(ctx, cBoard)
+		} else {
+			labels, err = s.organizer.ListCategories(ctx, cBoard)
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot list %ss of board %s:%w", kind, cBoard, err)
+		}
+		s.listedLabels[listKey] = true
+		for _, label := range labels {
+			s.state.saveLabel(kind, cBoard, label.Name, label.ID)
+		}
+		if id := s.state.getLabel(kind, cBoard, name); id != "" {
+			return id, nil
+		}
+	}
+	var id string
+	var err error
+	if kind == kindTag {
+		id, err = s.organizer.CreateTag(ctx, cBoard, name)
+	} else {
+		id, err = s.organizer.CreateCategory(ctx, cBoard, name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot create %s '%s' in board %s:%w", kind, name, cBoard, err)
+	}
+	s.Logger.Printf("\tCreated %s '%s' in board '%s'", kind, name, cBoard)
+	s.state.saveLabel(kind, cBoard, name, id)
+	return id, nil
+}
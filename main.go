@@ -2,54 +2,126 @@ package main
 
 import (
 	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/archive"
 	"github.com/Pleexy/zendesk-to-canny/canny"
+	"github.com/Pleexy/zendesk-to-canny/destination"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
 	"github.com/Pleexy/zendesk-to-canny/zendesk"
 	flag "github.com/spf13/pflag"
 	"log"
 	"os"
-	"strconv"
 	"strings"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "users":
+			runUsers(os.Args[2:])
+			return
+		}
+	}
 	flag.Usage = func() {
 		fmt.Fprint(os.Stderr,
 			`Usage: zendesk-to-canny \
-              -z-url zendesk_url -z-username zendesk_username -z-password zendesk_userpassword \
+              -z-url zendesk_url -z-username zendesk_username (-z-password zendesk_userpassword | -z-token zendesk_api_token) \
               -c-key canny_api_key \
               zendesk_topic_id:canny_board_id [zendesk_topic_id:canny_board_id [zendesk_topic_id:canny_board_id...]]
+       zendesk-to-canny --source (archive:path | file:path) -c-key canny_api_key topic_id:canny_board_id [...]
+       zendesk-to-canny export --help
+       zendesk-to-canny users --help
 Options:
-  --z-url url                  Required. Zendesk URL (e.g. https://your_company.zendesk.com) 
-  --z-username username        Required. User name to access Zendesk API
-  --z-password pass            Required. User password to access Zendesk API
-  --c-key apiKey               Required. Canny API key
+  --source source              Optional. Where to read posts from: 'zendesk' (default) to load them from Zendesk API,
+                                         'archive:path' to read an archive created by export command,
+                                         or 'file:path' to read a JSON or CSV file of another feedback tool (see README for the file schema).
+                                         Zendesk options are not required for an archive or a file.
+  --destination destination    Optional. Where to write posts to: 'canny' (default), or 'files:dir' to write a Markdown and a JSON file per post
+                                         to dir for a review before the import. Canny options are not required for files.
+                                         Use a separate state file for files.
+`+zendeskUsage+`  --c-key apiKey               Required. Canny API key
   --c-url url                  Optional. Canny APU URL. Default https://canny.io
   --default-user userID        Optional. Default user id (from Canny) which will be used for posts and comments where user is missing in Zendesk.
                                          If not provided, posts and comments without user will be skipped.
   --parallel n                 Optional. Number of parallel loads from Zendesk. Default is 10
   --state file                 Optional. State file. Default ./state.json
-  --agent zendeskID:cannyID    Optional. Specify mapping between Zendesk agents and Canny admins, if post/comments/votes authored by admins.
+  --agent sourceID:cannyID     Optional. Specify mapping between Zendesk (or other source) agents and Canny admins, if post/comments/votes authored by admins.
                                          Can be provided multiple times.
-  --verbose                    Print verbose logging
+  --map-email email:cannyID    Optional. Map users with the email (ann@example.com) or email domain (@example.com) to a Canny user,
+                                         e.g. all staff to a Canny admin. Can be provided multiple times.
+  --merge-by-email             Optional. Map source users sharing an email to the same Canny user
+  --no-email policy            Optional. What to do with users without email: 'create' (default) Canny user by name and external ID,
+                                         map to 'default' user, create with a 'synthetic' user ID, or 'skip' their posts, comments and votes
+  --anonymize                  Optional. Create Canny users with anonymized names and without emails. Vote counts are kept.
+                                         Only anonymized user details are saved to the state file.
+  --map-agents                 Optional. Map Zendesk agents and admins to Canny admins with the same email
+  --agent-fallback cannyID     Optional. Canny admin for agents and admins which are not found by email.
+                                         Without --map-agents all agents and admins are mapped to it.
+  --user-field field[:name]    Optional. Send the user field to Canny as a user custom field, named as the field or name.
+                                         Standard fields are role, locale, time_zone and organization_id, other names are Zendesk
+                                         custom user fields. Can be provided multiple times. User avatars are always sent.
+  --companies                  Optional. Load Zendesk organizations of users and attach them to Canny users as companies
+  --monthly-spend-field field  Optional. Zendesk organization field with monthly spend of the company, used with --companies
+  --post-field field[:name]    Optional. Send the post field to Canny as a post custom field, named as the field or name, e.g.
+                                         --post-field url:zendeskURL. Fields are id, url, topic, topic_name, created_at, vote_count
+                                         and vote_sum. Can be provided multiple times.
+  --downvotes policy           Optional. What to do with Zendesk downvotes, as Canny has upvotes only: 'skip' (default) them,
+                                         record each as an internal 'comment' of the voter, or count them in a post custom 'field'
+  --downvotes-field name       Optional. Post custom field for --downvotes field. Default downvotes
+  --tag-by sources             Optional. Comma separated list of what to tag Canny posts by: 'topic' name, Zendesk 'content-tags',
+                                         'flags' to tag featured and pinned posts. Missing tags are created.
+  --category-by source         Optional. Put Canny posts into a category named as the 'topic', or as the first Zendesk 'content-tag'.
+                                         Missing categories are created.
+  --retry-failed               Optional. Migrate only comments and votes queued in the state file because they failed to load or migrate
+  --title-template template    Optional. Go text/template of Canny post title. Default '{{.Title}}'
+  --details-template template  Optional. Go text/template of Canny post details. Default '{{.Details}}'
+  --comment-template template  Optional. Go text/template of Canny comment body. Default '{{.Body}}'.
+                                         A template starting with @ is read from the file, e.g. @details.tmpl
+  --templates file             Optional. JSON file with "title", "details" and "comment" templates. Template options override it
+`+filterUsage+httpUsage+`  --verbose                    Print verbose logging
   --help                       Print usage
 Arguments:
   Pairs of zendesk_topic_id:canny_board_id, where
-     zendesk_topic_id - Zendesk Help Center topic ID to load posts from (e.g. 115000153468-Integrations)
+     zendesk_topic_id - Zendesk Help Center topic ID to load posts from (e.g. 115000153468-Integrations), or a topic of a file source
      canny_board_id - ID of Canny board to create posts in. Multiple Zendesk topics can be mapped to the same Canny board.
+  Topics are migrated in the provided order, posts of a topic in the order they were created in Zendesk.
 `)
 	}
 
 	helpPtr := flag.Bool("help", false, "")
 	verbosePtr := flag.Bool("verbose", false, "")
-	zURLPrt := flag.String("z-url", "", "")
-	zUsernamePtr := flag.String("z-username", "", "")
-	zPasswordPtr := flag.String("z-password", "", "")
+	sourcePtr := flag.String("source", "zendesk", "")
+	destinationPtr := flag.String("destination", "canny", "")
+	zFlags := addZendeskFlags(flag.CommandLine)
 	cKeyPtr := flag.String("c-key", "", "")
 	cURLPtr := flag.String("c-url", "https://canny.io", "")
 	statePtr := flag.String("state", "./state.json", "")
 	defaultUserPtr := flag.String("default-user", "", "")
 	parallelPtr := flag.Int("parallel", 10, "")
 	agentsPtr := flag.StringSlice("agent", []string{}, "")
+	mapEmailsPtr := flag.StringSlice("map-email", []string{}, "")
+	mergeByEmailPtr := flag.Bool("merge-by-email", false, "")
+	noEmailPtr := flag.String("no-email", string(NoEmailCreate), "")
+	anonymizePtr := flag.Bool("anonymize", false, "")
+	mapAgentsPtr := flag.Bool("map-agents", false, "")
+	agentFallbackPtr := flag.String("agent-fallback", "", "")
+	userFieldsPtr := flag.StringSlice("user-field", []string{}, "")
+	companiesPtr := flag.Bool("companies", false, "")
+	monthlySpendPtr := flag.String("monthly-spend-field", "", "")
+	tagByPtr := flag.StringSlice("tag-by", []string{}, "")
+	categoryByPtr := flag.String("category-by", "", "")
+	postFieldsPtr := flag.StringSlice("post-field", []string{}, "")
+	downvotesPtr := flag.String("downvotes", string(DownvoteSkip), "")
+	downvotesFieldPtr := flag.String("downvotes-field", defaultDownvotesField, "")
+	retryFailedPtr := flag.Bool("retry-failed", false, "")
+	titleTemplatePtr := flag.String("title-template", "", "")
+	detailsTemplatePtr := flag.String("details-template", "", "")
+	commentTemplatePtr := flag.String("comment-template", "", "")
+	templatesPtr := flag.String("templates", "", "")
+	fFlags := addFilterFlags(flag.CommandLine)
+	hFlags := addHTTPFlags(flag.CommandLine)
 
 	flag.Parse()
 
@@ -58,71 +130,172 @@ Arguments:
 		os.Exit(0)
 	}
 
-	if *zURLPrt == "" || *zUsernamePtr == "" || *zPasswordPtr == "" || *cKeyPtr == "" {
-		fmt.Fprint(os.Stderr, "-z-url, -z-username, -z-password, -c-key are required")
-		flag.Usage()
-		os.Exit(1)
+	if *cKeyPtr == "" && *destinationPtr == "canny" && !hFlags.replaying() {
+		exitWithUsage(flag.Usage, "-c-key is required")
+	}
+	httpClient, err := hFlags.client()
+	if err != nil {
+		exitWithUsage(flag.Usage, "%v", err)
+	}
+	labels, err := ParsePostLabels(*tagByPtr, *categoryByPtr)
+	if err != nil {
+		exitWithUsage(flag.Usage, "%v", err)
+	}
+	var templatesConfig TemplatesConfig
+	if *templatesPtr != "" {
+		if templatesConfig, err = LoadTemplatesConfig(*templatesPtr); err != nil {
+			exitWithUsage(flag.Usage, "%v", err)
+		}
+	}
+	// template flags override templates of the config
+	if *titleTemplatePtr != "" {
+		templatesConfig.Title = *titleTemplatePtr
+	}
+	if *detailsTemplatePtr != "" {
+		templatesConfig.Details = *detailsTemplatePtr
+	}
+	if *commentTemplatePtr != "" {
+		templatesConfig.Comment = *commentTemplatePtr
+	}
+	templates, err := ParseTemplates(templatesConfig.Title, templatesConfig.Details, templatesConfig.Comment)
+	if err != nil {
+		exitWithUsage(flag.Usage, "%v", err)
+	}
+	filter, err := fFlags.filter()
+	if err != nil {
+		exitWithUsage(flag.Usage, "%v", err)
+	}
+	if filter != nil && *sourcePtr != "zendesk" {
+		exitWithUsage(flag.Usage, "post filters are supported with zendesk source only")
+	}
+	var source feedback.Source
+	switch {
+	case *sourcePtr == "zendesk":
+		var zClient *zendesk.Client
+		zClient, err = zFlags.client(httpClient, hFlags.replaying())
+		if zClient != nil {
+			zClient.Parallel = *parallelPtr
+			zClient.LoadOrganizations = *companiesPtr
+			zClient.LoadTopicName = labels.TagTopic || labels.CategoryBy == LabelTopic
+			zClient.LoadContentTags = labels.TagContentTags || labels.CategoryBy == LabelContentTag
+			zClient.Filter = filter
+			source = zClient
+		}
+	case strings.HasPrefix(*sourcePtr, "file:"):
+		source = &feedback.FileSource{Path: strings.TrimPrefix(*sourcePtr, "file:")}
+	case strings.HasPrefix(*sourcePtr, "archive:"):
+		source, err = archive.Open(strings.TrimPrefix(*sourcePtr, "archive:"))
+	default:
+		err = fmt.Errorf("invalid source %s", *sourcePtr)
+	}
+	if err != nil {
+		exitWithUsage(flag.Usage, "%v", err)
 	}
 
 	args := flag.Args()
 	if len(args) == 0 {
-		_, _ = fmt.Fprint(os.Stderr, "at least one pair of zendesk_topic_id:canny_board_id MUST be provided")
-		flag.Usage()
-		os.Exit(1)
+		exitWithUsage(flag.Usage, "at least one pair of zendesk_topic_id:canny_board_id MUST be provided")
 	}
-	topics := make(map[string]string)
+	topics := make([]TopicMapping, 0, len(args))
+	seenTopics := make(map[string]bool)
 	for _, arg := range args {
 		parts := strings.Split(arg, ":")
 		if len(parts) != 2 {
-			_, _ = fmt.Fprint(os.Stderr, "invalid arguments format")
-			flag.Usage()
-			os.Exit(1)
+			exitWithUsage(flag.Usage, "invalid arguments format")
+		}
+		if seenTopics[parts[0]] {
+			exitWithUsage(flag.Usage, "topic %s is provided more than once", parts[0])
 		}
-		topics[parts[0]] = parts[1]
+		seenTopics[parts[0]] = true
+		topics = append(topics, TopicMapping{Topic: parts[0], Board: parts[1]})
 	}
-	var agents map[int64]string
+	var agents map[string]string
 	if len(*agentsPtr) > 0 {
-		agents = make(map[int64]string)
+		agents = make(map[string]string)
 		for _, agent := range *agentsPtr {
 			parts := strings.Split(agent, ":")
-			if len(parts) != 2 {
-				_, _ = fmt.Fprintf(os.Stderr, "invalid agent format %s", agent)
-				flag.Usage()
-				os.Exit(1)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				exitWithUsage(flag.Usage, "invalid agent format %s", agent)
 			}
-			zID, err := strconv.ParseInt(parts[0], 10, 64)
-			if err != nil {
-				_, _ = fmt.Fprintf(os.Stderr, "invalid agent format %s", agent)
-				flag.Usage()
-				os.Exit(1)
-			}
-			agents[zID] = parts[1]
+			agents[parts[0]] = parts[1]
 		}
 	}
 
-	cClient := &canny.Client{
-		APIKey:  *cKeyPtr,
-		BaseURL: *cURLPtr,
+	rules := UserRules{
+		EmailMapping:      make(map[string]string),
+		MergeByEmail:      *mergeByEmailPtr,
+		Anonymize:         *anonymizePtr,
+		MapAgents:         *mapAgentsPtr,
+		AgentFallback:     *agentFallbackPtr,
+		Companies:         *companiesPtr,
+		MonthlySpendField: *monthlySpendPtr,
+	}
+	for _, mapping := range *mapEmailsPtr {
+		sep := strings.LastIndex(mapping, ":")
+		if sep <= 0 || sep == len(mapping)-1 {
+			exitWithUsage(flag.Usage, "invalid map-email format %s", mapping)
+		}
+		rules.EmailMapping[strings.ToLower(mapping[:sep])] = mapping[sep+1:]
 	}
-	zClient := &zendesk.Client{
-		Username: *zUsernamePtr,
-		Password: *zPasswordPtr,
-		BaseURL:  *zURLPrt,
+	rules.CustomFields = parseFieldMapping("user-field", *userFieldsPtr)
+	postFields := parseFieldMapping("post-field", *postFieldsPtr)
+	downvotes, err := ParseDownvotePolicy(*downvotesPtr)
+	if err != nil {
+		exitWithUsage(flag.Usage, "%v", err)
+	}
+	rules.NoEmail, err = ParseNoEmailPolicy(*noEmailPtr)
+	if err != nil {
+		exitWithUsage(flag.Usage, "%v", err)
+	}
+
+	var dest destination.Destination
+	switch {
+	case *destinationPtr == "canny":
+		dest = &destination.Canny{Client: &canny.Client{
+			APIKey:     *cKeyPtr,
+			BaseURL:    *cURLPtr,
+			HTTPClient: httpClient,
+		}}
+	case strings.HasPrefix(*destinationPtr, "files:"):
+		dest = &destination.Files{Dir: strings.TrimPrefix(*destinationPtr, "files:")}
+	default:
+		exitWithUsage(flag.Usage, "invalid destination %s", *destinationPtr)
 	}
 	migration := &Migration{
-		ZClient:       zClient,
-		CClient:       cClient,
+		Source:        source,
+		Destination:   dest,
 		Topics:        topics,
 		Verbose:       *verbosePtr,
 		DefaultUserID: *defaultUserPtr,
-		ParallelLoad:  *parallelPtr,
 		StateFile:     *statePtr,
 		Logger:        log.New(os.Stdout, "", 0),
 		UserMapping:   agents,
+		UserRules:     rules,
+		Labels:        labels,
+		PostFields:    postFields,
+		Downvotes:     downvotes,
+		DownvoteField: *downvotesFieldPtr,
+		RetryFailed:   *retryFailedPtr,
+		Templates:     templates,
 	}
 
-	err := migration.Migrate()
+	ctx, cancel := interruptibleContext(migration.Logger)
+	defer cancel()
+	err = migration.Migrate(ctx)
 	if err != nil {
 		_, _ = fmt.Fprint(os.Stderr, err)
 	}
 }
+
+//parseFieldMapping parses field[:name] values of the flag to a map of field to name
+func parseFieldMapping(flagName string, values []string) map[string]string {
+	fields := make(map[string]string)
+	for _, value := range values {
+		parts := strings.Split(value, ":")
+		if len(parts) > 2 || parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+			exitWithUsage(flag.Usage, "invalid %s format %s", flagName, value)
+		}
+		fields[parts[0]] = parts[len(parts)-1]
+	}
+	return fields
+}
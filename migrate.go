@@ -1,51 +1,146 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/Pleexy/zendesk-to-canny/canny"
+	"github.com/Pleexy/zendesk-to-canny/destination"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
 	"github.com/Pleexy/zendesk-to-canny/zendesk"
 	"github.com/kennygrant/sanitize"
-	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 )
 
 // Migration contains migration parameters and methods
 type Migration struct {
-	ZClient       *zendesk.Client
-	CClient       *canny.Client
-	Topics        map[string]string
+	Source        feedback.Source
+	Destination   destination.Destination
+	Topics        []TopicMapping // migrated in this order
 	Verbose       bool
 	DefaultUserID string
-	ParallelLoad  int
 	StateFile     string
-	UserMapping   map[int64]string
-	state         map[string]map[string]string // contains mapping of [zendesk_topic: ['<zendesk_type><zendesk_id>':'canny_id']]
+	UserMapping   map[string]string // source user ID to Canny user ID, overrides mapping saved in state
+	UserRules     UserRules
+	Labels        PostLabels
+	PostFields    map[string]string // post fields (see feedback.Post.Field) to names of Canny post custom fields
+	Downvotes     DownvotePolicy    // DownvoteSkip if empty
+	DownvoteField string            // post custom field for DownvoteField policy, "downvotes" if empty
+	RetryFailed   bool              // migrate only posts queued for retry because their comments or votes were not loaded
+	Templates     Templates         // templates of post title, details and comment body, defaults if not set
+	state         *State
+	emailUsers    map[string]string // lower case email to Canny user ID, to merge users by email
+	admins        map[string]string // lower case email to Canny admin ID, loaded on the first agent
+	agents        map[string]string // source agent ID to Canny admin ID, for the report
+	organizer     destination.Organizer
+	listedLabels  map[string]bool // '<tag|category>_<board_id>' listed in this run
 	Logger        *log.Logger
 }
 
-//Migrate performs a migration for specified topics
-func (s *Migration) Migrate() error {
-	if err := s.loadState(); err != nil {
+//TopicMapping maps a source topic to a Canny board
+type TopicMapping struct {
+	Topic string
+	Board string
+}
+
+//InterruptedError is returned by Migrate when the context is cancelled before all topics are migrated.
+//State of everything migrated so far is saved before it is returned.
+type InterruptedError struct {
+	Posts int // number of posts migrated before the interruption
+	Err   error
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("migration interrupted after %d posts, state is saved: %v", e.Posts, e.Err)
+}
+
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+//Migrate performs a migration for specified topics. When ctx is cancelled, it stops, saves state
+//and returns *InterruptedError
+func (s *Migration) Migrate(ctx context.Context) error {
+	state, err := loadState(s.StateFile)
+	if err != nil {
 		return fmt.Errorf("cannot load State file:%w", err)
 	}
+	s.state = state
+	if s.Labels.enabled() {
+		organizer, ok := s.Destination.(destination.Organizer)
+		if !ok {
+			return fmt.Errorf("destination doesn't support tags and categories")
+		}
+		s.organizer = organizer
+		s.listedLabels = make(map[string]bool)
+	}
+	var loader feedback.PostLoader
+	if s.RetryFailed {
+		var ok bool
+		if loader, ok = s.Source.(feedback.PostLoader); !ok {
+			return fmt.Errorf("source doesn't support retrying posts")
+		}
+	}
 	if s.UserMapping == nil {
-		s.UserMapping = make(map[int64]string)
-	}
-	for zTopic, cBoard := range s.Topics {
-		var success, fail int
-		s.Logger.Printf("Migrating topic '%s' to board '%s'", zTopic, cBoard)
-		posts, errs, fatalError := s.ZClient.GetPosts(zTopic, s.ParallelLoad, s.printZPost, s.printErr)
-		if fatalError != nil {
-			s.Logger.Printf("FATAL ERROR while loading posts for %s, skipping - %v", zTopic, fatalError)
+		s.UserMapping = make(map[string]string)
+	}
+	s.emailUsers = make(map[string]string)
+	s.admins = nil
+	s.agents = make(map[string]string)
+	for _, userState := range s.state.Users {
+		if userState.Email != "" && userState.CannyID != "" {
+			s.emailUsers[strings.ToLower(userState.Email)] = userState.CannyID
+		}
+	}
+	// anonymized users are saved without emails, so they are not usable for email rules
+	if cache, ok := s.Source.(feedback.UserCache); ok && !s.UserRules.Anonymize {
+		cache.AddUsers(s.state.knownUsers())
+	}
+	var migrated int
+	for _, mapping := range s.Topics {
+		topic, cBoard := mapping.Topic, mapping.Board
+		if ctx.Err() != nil {
+			break
+		}
+		if s.RetryFailed {
+			migrated += s.retryTopic(ctx, loader, topic, cBoard)
 			continue
 		}
-		s.Logger.Printf("Loaded %d posts with %d error", len(posts), len(errs))
-		for _, post := range posts {
-			err := s.migratePost(post, zTopic, cBoard)
-			if err != nil {
-				s.Logger.Printf("\tError while creating Canny post '%s' from Zendesk post %d: %v", post.Title, post.ID, err)
+		var success, fail, skipped, filtered, loadErrors, voteDiffs, queued int
+		s.Logger.Printf("Migrating topic '%s' to board '%s'", topic, cBoard)
+		stream := s.Source.Posts(ctx, topic, func(err error) {
+			var skipErr *feedback.SkippedError
+			if errors.As(err, &skipErr) {
+				filtered++
+				s.Logger.Printf("\tPost '%s' (%s) is filtered out: %s", skipErr.Title, skipErr.ID, skipErr.Reason)
+				return
+			}
+			loadErrors++
+			s.printErr(err)
+		})
+		for post := range stream.Posts {
+			s.printPost(post)
+			if ctx.Err() != nil {
+				break
+			}
+			err := s.migratePost(ctx, post, topic, cBoard)
+			if ctx.Err() != nil {
+				// the post is partially migrated, its created objects are in state
+				break
+			}
+			if err == nil {
+				if diff := s.reconcileVotes(topic, post); diff != "" {
+					s.Logger.Printf("\tVotes of post '%s' (%s) differ: %s", post.Title, post.ID, diff)
+					voteDiffs++
+				}
+			}
+			if err == errUserSkipped {
+				s.Logger.Printf("\tPost '%s' is skipped because of its user", post.Title)
+				skipped++
+			} else if err != nil {
+				s.Logger.Printf("\tError while creating Canny post '%s' from post %s: %v", post.Title, post.ID, err)
 				fail++
 			} else {
 				if s.Verbose {
@@ -53,110 +148,255 @@ func (s *Migration) Migrate() error {
 				}
 				success++
 			}
+			// comments and votes of a created post are queued whatever errors of other comments and votes are
+			if err != errUserSkipped && s.getIDFromState(topic, "post", post.ID) != "" && s.state.queueRetry(topic, post, err) {
+				s.Logger.Printf("\tComments or votes of post '%s' (%s) are incomplete or failed, the post is queued for retry", post.Title, post.ID)
+				queued++
+			}
+		}
+		if ctx.Err() != nil {
+			migrated += success
+			break
+		}
+		if fatalError := stream.Err(); fatalError != nil {
+			s.Logger.Printf("FATAL ERROR while loading posts for %s, topic is not completed - %v", topic, fatalError)
+		}
+		s.Logger.Printf("Loaded %d posts with %d errors, %d posts filtered out", success+fail+skipped, loadErrors, filtered)
+		migrated += success
+		s.Logger.Printf("Migrated topic '%s' to board '%s': %d posts, %d skipped, %d filtered out, %d errors, %d posts with different votes", topic, cBoard, success, skipped, filtered, fail, voteDiffs)
+		if queued > 0 {
+			s.Logger.Printf("%d posts of topic '%s' are queued for retry, run with --retry-failed to complete them", queued, topic)
 		}
-		s.Logger.Printf("Migrated topic '%s' to board '%s': %d posts, %d errors", zTopic, cBoard, success, fail)
 	}
-	if err := s.saveState(); err != nil {
-		s.Logger.Print(s.state)
-		return fmt.Errorf("cannot save State file:%w. State is printed above, add to state file manually before repeating operation", err)
+	if stats, ok := s.Source.(statsSource); ok && s.Verbose {
+		printStats(s.Logger, stats.Stats())
 	}
-	return nil
-}
-
-func (s *Migration) loadState() error {
-	if s.StateFile == "" || !fileExists(s.StateFile) {
-		s.state = make(map[string]map[string]string)
-		return nil
+	if s.UserRules.mapsAgents() {
+		var mapped int
+		for _, adminID := range s.agents {
+			if adminID != "" {
+				mapped++
+			}
+		}
+		s.Logger.Printf("Mapped %d of %d agents to Canny admins", mapped, len(s.agents))
 	}
-	raw, err := ioutil.ReadFile(s.StateFile)
-	if err != nil {
-		return err
+	if err := s.state.save(s.StateFile); err != nil {
+		data, _ := json.Marshal(s.state)
+		s.Logger.Print(string(data))
+		return fmt.Errorf("cannot save State file:%w. State is printed above, add to state file manually before repeating operation", err)
 	}
-	err = json.Unmarshal(raw, &s.state)
-	return err
-}
-
-func (s *Migration) saveState() error {
-	data, err := json.MarshalIndent(s.state, "", " ")
-	if err != nil {
-		return err
+	if ctx.Err() != nil {
+		return &InterruptedError{Posts: migrated, Err: ctx.Err()}
 	}
-	return ioutil.WriteFile(s.StateFile, data, 0644)
+	return nil
 }
 
-func (s *Migration) migratePost(post *zendesk.Post, zTopic, cBoard string) error {
+func (s *Migration) migratePost(ctx context.Context, post *feedback.Post, topic, cBoard string) error {
 	var err error
-	postID := s.getIDFromState(zTopic, "post", post.ID)
+	data := s.postData(post, cBoard)
+	postID := s.getIDFromState(topic, "post", post.ID)
 	if postID == "" {
-		postID, err = s.createPost(post, cBoard)
+		postID, err = s.createPost(ctx, post, &data, cBoard)
 		if err != nil {
 			return err
 		}
-		s.saveIDToState(zTopic, "post", post.ID, postID)
+		s.saveIDToState(topic, "post", post.ID, postID)
 	} else {
 		if s.Verbose {
 			s.Logger.Printf("\tpost '%s' is found in State - skipping", post.Title)
 		}
+		if s.Templates.Comment != nil && s.hasNewComments(topic, post) {
+			// the author is resolved again for DefaultUser of comment templates, it is known since the post was created
+			if userID, err := s.resolveUser(ctx, post.Author, "post"); err == nil {
+				data.DefaultUser = userID == s.DefaultUserID
+			}
+		}
+	}
+	var errs errorList
+	if s.organizer != nil {
+		if err := s.tagPost(ctx, post, topic, cBoard, postID); err != nil {
+			errs = append(errs, err)
+		}
 	}
 	for _, comment := range post.Comments {
-		commentID := s.getIDFromState(zTopic, "comment", comment.ID)
+		commentID := s.getIDFromState(topic, "comment", comment.ID)
 		if commentID != "" {
 			if s.Verbose {
-				s.Logger.Printf("\tComment '%d' is found in State - skipping", comment.ID)
+				s.Logger.Printf("\tComment '%s' is found in State - skipping", comment.ID)
+			}
+			continue
+		}
+		commentID, err := s.createComment(ctx, data, comment, postID)
+		if err == errUserSkipped {
+			if s.Verbose {
+				s.Logger.Printf("\tComment '%s' is skipped because of its user", comment.ID)
 			}
 			continue
 		}
-		commentID, err := s.createComment(comment, postID)
 		if err != nil {
-			return err
+			errs = append(errs, &objectError{objType: "comment", id: comment.ID, err: err})
+			continue
 		}
-		s.saveIDToState(zTopic, "comment", comment.ID, commentID)
+		s.saveIDToState(topic, "comment", comment.ID, commentID)
 	}
 
-	for _, vote := range post.UserVotes {
-		voteSuccess := s.getIDFromState(zTopic, "vote", vote.ID)
+	for _, vote := range post.Votes {
+		voteSuccess := s.getIDFromState(topic, "vote", vote.ID)
 		if voteSuccess != "" || vote.User == nil {
 			continue
 		}
-		voteSuccess, err := s.createVote(vote, postID)
+		if vote.IsDown() && s.Downvotes != DownvoteComment {
+			continue
+		}
+		var err error
+		if vote.IsDown() {
+			voteSuccess, err = s.createDownvoteComment(ctx, vote, postID)
+		} else {
+			voteSuccess, err = s.createVote(ctx, vote, postID)
+		}
+		if err == errUserSkipped {
+			continue
+		}
 		if err != nil {
-			return err
+			errs = append(errs, &objectError{objType: "vote", id: vote.ID, err: err})
+			continue
 		}
-		s.saveIDToState(zTopic, "vote", vote.ID, voteSuccess)
+		s.saveIDToState(topic, "vote", vote.ID, voteSuccess)
+	}
+	if len(errs) > 0 {
+		return errs
 	}
 	return nil
 }
 
-func (s *Migration) createPost(post *zendesk.Post, cBoard string) (string, error) {
-	userID, err := s.resolveUser(post.Author, "post")
+//hasNewComments reports whether the post has comments which are not migrated yet
+func (s *Migration) hasNewComments(topic string, post *feedback.Post) bool {
+	for _, comment := range post.Comments {
+		if s.getIDFromState(topic, "comment", comment.ID) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+//errorList aggregates errors of comments and votes of a single post
+type errorList []error
+
+//objectError is an error of a comment or vote
+type objectError struct {
+	objType string // comment or vote
+	id      string
+	err     error
+}
+
+func (e *objectError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.objType, e.id, e.err)
+}
+
+func (e *objectError) Unwrap() error {
+	return e.err
+}
+
+func (e errorList) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors: %s", len(e), strings.Join(msgs, "; "))
+}
+
+//Is reports whether any of errors matches target
+func (e errorList) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+//createPost creates the post in Canny. DefaultUser of data is set by the resolved author for comments of the post
+func (s *Migration) createPost(ctx context.Context, post *feedback.Post, data *PostData, cBoard string) (string, error) {
+	userID, err := s.resolveUser(ctx, post.Author, "post")
 	if err != nil {
 		return "", err
 	}
-	return s.CClient.CreatePost(canny.CreatePost{
-		AuthorID: userID,
-		BoardID:  cBoard,
-		Details:  sanitizeString(post.Details),
-		Title:    sanitizeString(post.Title),
+	data.DefaultUser = userID == s.DefaultUserID
+	title, err := execute(s.Templates.Title, defaultTemplates.Title, data)
+	if err != nil {
+		return "", err
+	}
+	details, err := execute(s.Templates.Details, defaultTemplates.Details, data)
+	if err != nil {
+		return "", err
+	}
+	var categoryID string
+	if category := strings.TrimSpace(s.Labels.category(post)); category != "" {
+		categoryID, err = s.labelID(ctx, kindCategory, cBoard, category)
+		if err != nil {
+			return "", err
+		}
+	}
+	customFields := s.postCustomFields(post)
+	if s.Downvotes == DownvoteField {
+		if customFields == nil {
+			customFields = make(map[string]interface{})
+		}
+		field := s.DownvoteField
+		if field == "" {
+			field = defaultDownvotesField
+		}
+		customFields[field] = downvotes(post)
+	}
+	return s.Destination.CreatePost(ctx, destination.Post{
+		AuthorID:     userID,
+		BoardID:      cBoard,
+		CategoryID:   categoryID,
+		CustomFields: customFields,
+		Details:      details,
+		Title:        title,
 	})
 }
 
-func (s *Migration) createComment(comment *zendesk.Comment, postID string) (string, error) {
-	userID, err := s.resolveUser(comment.Author, "comment")
+//postCustomFields returns values of PostFields of the post, or nil if none of fields is set
+func (s *Migration) postCustomFields(post *feedback.Post) map[string]interface{} {
+	var fields map[string]interface{}
+	for field, cannyField := range s.PostFields {
+		value := post.Field(field)
+		if value == nil {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]interface{})
+		}
+		fields[cannyField] = value
+	}
+	return fields
+}
+
+func (s *Migration) createComment(ctx context.Context, post PostData, comment *feedback.Comment, postID string) (string, error) {
+	userID, err := s.resolveUser(ctx, comment.Author, "comment")
 	if err != nil {
 		return "", err
 	}
-	return s.CClient.CreateComment(canny.CreateComment{
+	data := s.commentData(post, comment)
+	data.DefaultUser = userID == s.DefaultUserID
+	value, err := execute(s.Templates.Comment, defaultTemplates.Comment, data)
+	if err != nil {
+		return "", err
+	}
+	return s.Destination.CreateComment(ctx, destination.Comment{
 		AuthorID: userID,
 		PostID:   postID,
-		Value:    sanitizeString(comment.Body),
+		Value:    value,
 	})
 }
-func (s *Migration) createVote(vote *zendesk.Vote, postID string) (string, error) {
-	userID, err := s.resolveUser(vote.User, "vote")
+func (s *Migration) createVote(ctx context.Context, vote *feedback.Vote, postID string) (string, error) {
+	userID, err := s.resolveUser(ctx, vote.User, "vote")
 	if err != nil {
 		return "", err
 	}
-	err = s.CClient.CreateVote(canny.CreateVote{
+	err = s.Destination.CreateVote(ctx, destination.Vote{
 		PostID:  postID,
 		VoterID: userID,
 	})
@@ -166,7 +406,7 @@ func (s *Migration) createVote(vote *zendesk.Vote, postID string) (string, error
 	return "s", nil
 }
 
-func (s *Migration) resolveUser(user *zendesk.User, objType string) (string, error) {
+func (s *Migration) resolveUser(ctx context.Context, user *feedback.User, objType string) (string, error) {
 	var userID string
 	var err error
 	if user == nil {
@@ -175,7 +415,7 @@ func (s *Migration) resolveUser(user *zendesk.User, objType string) (string, err
 		}
 		userID = s.DefaultUserID
 	} else {
-		userID, err = s.findOrCreateUser(user)
+		userID, err = s.findOrCreateUser(ctx, user)
 		if err != nil {
 			return "", err
 		}
@@ -183,44 +423,156 @@ func (s *Migration) resolveUser(user *zendesk.User, objType string) (string, err
 	return userID, nil
 }
 
-func (s *Migration) findOrCreateUser(user *zendesk.User) (string, error) {
+func (s *Migration) findOrCreateUser(ctx context.Context, user *feedback.User) (string, error) {
 	if knownUserID := s.UserMapping[user.ID]; knownUserID != "" {
+		s.saveUser(user, "")
 		return knownUserID, nil
 	}
-	userID, err := s.CClient.FindOrCreateUser(canny.FindOrCreateUser{
-		Created: user.CreatedAt,
-		Email:   user.Email,
-		Name:    user.Name,
-		UserID:  user.ExternalID,
-	})
+	if knownUserID := s.UserRules.mappedUser(user.Email); knownUserID != "" {
+		s.saveUser(user, "")
+		return knownUserID, nil
+	}
+	if user.IsStaff() && s.UserRules.mapsAgents() {
+		adminID, err := s.mapAgent(ctx, user)
+		if err != nil {
+			return "", err
+		}
+		if adminID != "" {
+			s.saveUser(user, "")
+			return adminID, nil
+		}
+	}
+	if userState := s.state.Users[user.ID]; userState != nil && userState.CannyID != "" {
+		return userState.CannyID, nil
+	}
+	email := strings.ToLower(user.Email)
+	if s.UserRules.MergeByEmail && email != "" {
+		if knownUserID := s.emailUsers[email]; knownUserID != "" {
+			s.saveUser(user, knownUserID)
+			return knownUserID, nil
+		}
+	}
+	cannyUser := destination.User{
+		AvatarURL:    user.AvatarURL,
+		Companies:    s.UserRules.companies(user),
+		Created:      user.CreatedAt,
+		CustomFields: s.UserRules.customFields(user),
+		Email:        user.Email,
+		Name:         user.Name,
+		UserID:       user.ExternalID,
+	}
+	if user.Email == "" {
+		switch s.UserRules.NoEmail {
+		case NoEmailDefault:
+			if s.DefaultUserID == "" {
+				return "", fmt.Errorf("user %s doesn't have email and default user is not specified", user.ID)
+			}
+			return s.DefaultUserID, nil
+		case NoEmailSkip:
+			return "", errUserSkipped
+		case NoEmailSynthetic:
+			if cannyUser.UserID == "" {
+				cannyUser.UserID = syntheticUserID(user)
+			}
+		}
+	}
+	if s.UserRules.Anonymize {
+		anonymous := anonymize(user)
+		cannyUser = destination.User{
+			Created: anonymous.CreatedAt,
+			Name:    anonymous.Name,
+			UserID:  anonymous.ExternalID,
+		}
+	}
+	userID, err := s.Destination.FindOrCreateUser(ctx, cannyUser)
 	if err != nil {
 		return "", err
 	}
-	s.UserMapping[user.ID] = userID
+	s.saveUser(user, userID)
+	if email != "" {
+		s.emailUsers[email] = userID
+	}
 	return userID, nil
 }
 
-func (s *Migration) getIDFromState(zTopic string, objType string, id int64) string {
-	if s.state[zTopic] == nil {
-		return ""
+//mapAgent returns a Canny admin ID for a source agent or admin: an admin with the same email, or the fallback admin.
+//It returns empty string if the agent is not mapped and should be migrated as a regular user
+func (s *Migration) mapAgent(ctx context.Context, user *feedback.User) (string, error) {
+	if adminID, ok := s.agents[user.ID]; ok {
+		return adminID, nil
+	}
+	if s.UserRules.MapAgents && s.admins == nil {
+		if err := s.loadAdmins(ctx); err != nil {
+			return "", err
+		}
+	}
+	adminID := s.admins[strings.ToLower(user.Email)]
+	switch {
+	case user.Email != "" && adminID != "":
+		s.Logger.Printf("\t%s '%s' (%s) is mapped to Canny admin %s by email", strings.Title(user.Role), user.Name, user.ID, adminID)
+	case s.UserRules.AgentFallback != "":
+		adminID = s.UserRules.AgentFallback
+		s.Logger.Printf("\t%s '%s' (%s) is mapped to fallback Canny admin %s", strings.Title(user.Role), user.Name, user.ID, adminID)
+	default:
+		s.Logger.Printf("\t%s '%s' (%s) is not found among Canny admins and is migrated as a regular user", strings.Title(user.Role), user.Name, user.ID)
 	}
-	return s.state[zTopic][formatKey(objType, id)]
+	s.agents[user.ID] = adminID
+	return adminID, nil
 }
 
-func (s *Migration) saveIDToState(zTopic string, objType string, id int64, cannyID string) {
-	if s.state[zTopic] == nil {
-		s.state[zTopic] = make(map[string]string)
+//loadAdmins loads admins of the destination to map agents by email
+func (s *Migration) loadAdmins(ctx context.Context) error {
+	s.admins = make(map[string]string)
+	lister, ok := s.Destination.(destination.AdminLister)
+	if !ok {
+		s.Logger.Printf("Destination cannot list admins, agents are mapped to the fallback admin only")
+		return nil
+	}
+	admins, err := lister.ListAdmins(ctx)
+	if err != nil {
+		s.admins = nil
+		return fmt.Errorf("cannot load Canny admins:%w", err)
+	}
+	for _, admin := range admins {
+		if admin.Email != "" {
+			s.admins[strings.ToLower(admin.Email)] = admin.ID
+		}
 	}
-	s.state[zTopic][formatKey(objType, id)] = cannyID
+	if s.Verbose {
+		s.Logger.Printf("Loaded %d Canny admins", len(admins))
+	}
+	return nil
+}
+
+//saveUser saves user and its Canny user ID to state. Only anonymized details are saved if users are anonymized
+func (s *Migration) saveUser(user *feedback.User, cannyID string) {
+	if s.UserRules.Anonymize {
+		user = anonymize(user)
+	}
+	s.state.saveUser(user, cannyID)
+}
+
+func (s *Migration) getIDFromState(topic string, objType string, id string) string {
+	return s.state.getID(topic, objType, id)
+}
+
+func (s *Migration) saveIDToState(topic string, objType string, id string, cannyID string) {
+	s.state.saveID(topic, objType, id, cannyID)
+}
+
+//statsSource is implemented by sources which count their API requests
+type statsSource interface {
+	Stats() zendesk.Stats
 }
 
-func formatKey(objType string, id int64) string {
-	return fmt.Sprintf("%s_%d", objType, id)
+func printStats(logger *log.Logger, stats zendesk.Stats) {
+	logger.Printf("Zendesk API: %d requests made, %d requests saved by %d users side-loaded with posts and comments",
+		stats.Requests, stats.SavedRequests, stats.SideloadedUsers)
 }
 
-func (s *Migration) printZPost(post *zendesk.Post) {
+func (s *Migration) printPost(post *feedback.Post) {
 	if s.Verbose {
-		s.Logger.Printf("\tLoaded post %d: '%s' with %d comments and %d votes", post.ID, post.Title, len(post.Comments), len(post.UserVotes))
+		s.Logger.Printf("\tLoaded post %s: '%s' with %d comments and %d votes", post.ID, post.Title, len(post.Comments), len(post.Votes))
 	}
 }
 func (s *Migration) printErr(err error) {
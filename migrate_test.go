@@ -0,0 +1,832 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/Pleexy/zendesk-to-canny/canny"
+	"github.com/Pleexy/zendesk-to-canny/canny/cannytest"
+	"github.com/Pleexy/zendesk-to-canny/destination"
+	"github.com/Pleexy/zendesk-to-canny/zendesk"
+	"github.com/Pleexy/zendesk-to-canny/zendesk/zendesktest"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testTopic = "100-Ideas"
+	testBoard = "board1"
+	testKey   = "canny-key"
+)
+
+type testEnv struct {
+	zendesk   *zendesktest.Server
+	canny     *cannytest.Server
+	stateFile string
+	adminID   string
+}
+
+//newTestEnv starts fake servers with a topic of 3 posts:
+//post 1 by user 11 with comments by 11, 12 and missing user 99, and votes by 12, 13
+//post 2 by user 12 with a vote by 11
+//post 3 by missing user 99
+func newTestEnv(t *testing.T) *testEnv {
+	z := zendesktest.NewServer()
+	t.Cleanup(z.Close)
+	c := cannytest.NewServer(testKey)
+	t.Cleanup(c.Close)
+	z.PageSize = 1
+	created := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	z.AddUsers(
+		&zendesk.User{ID: 11, Name: "Ann", Email: "ann@example.com", CreatedAt: created},
+		&zendesk.User{ID: 12, Name: "Bob", Email: "bob@example.com", CreatedAt: created},
+		&zendesk.User{ID: 13, Name: "Cid", Email: "cid@example.com", CreatedAt: created},
+	)
+	z.AddPost(testTopic, &zendesk.Post{ID: 1, Title: "Dark mode", Details: "<p>Please add dark mode</p>", AuthorID: 11},
+		[]*zendesk.Comment{
+			{ID: 101, Body: "<p>Yes</p>", AuthorID: 11},
+			{ID: 102, Body: "Agree", AuthorID: 12},
+			{ID: 103, Body: "Me too", AuthorID: 99},
+		},
+		[]*zendesk.Vote{{ID: 201, UserID: 12}, {ID: 202, UserID: 13}})
+	z.AddPost(testTopic, &zendesk.Post{ID: 2, Title: "Export to CSV", Details: "CSV please", AuthorID: 12},
+		nil, []*zendesk.Vote{{ID: 203, UserID: 11}})
+	z.AddPost(testTopic, &zendesk.Post{ID: 3, Title: "Orphan idea", Details: "Author is deleted", AuthorID: 99}, nil, nil)
+	return &testEnv{
+		zendesk:   z,
+		canny:     c,
+		stateFile: filepath.Join(t.TempDir(), "state.json"),
+		adminID:   c.AddAdmin(canny.FindOrCreateUser{Name: "Admin", Email: "admin@example.com"}),
+	}
+}
+
+//migration creates a new Migration, as a separate run of the tool would
+func (e *testEnv) migration() *Migration {
+	return &Migration{
+		Source:      &zendesk.Client{BaseURL: e.zendesk.URL, Parallel: 2, RetryBackoff: time.Millisecond},
+		Destination: &destination.Canny{Client: &canny.Client{APIKey: testKey, BaseURL: e.canny.URL}},
+		Topics:      []TopicMapping{{Topic: testTopic, Board: testBoard}},
+		StateFile:   e.stateFile,
+		Logger:      log.New(ioutil.Discard, "", 0),
+	}
+}
+
+func (e *testEnv) counts() (posts, comments, votes int) {
+	e.canny.Lock()
+	defer e.canny.Unlock()
+	return len(e.canny.Posts), len(e.canny.Comments), len(e.canny.Votes)
+}
+
+func (e *testEnv) assertCounts(t *testing.T, posts, comments, votes int) {
+	t.Helper()
+	p, c, v := e.counts()
+	if p != posts || c != comments || v != votes {
+		t.Errorf("canny has %d posts, %d comments, %d votes; want %d, %d, %d", p, c, v, posts, comments, votes)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	env := newTestEnv(t)
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	env.assertCounts(t, 3, 3, 3)
+
+	post := env.canny.PostByTitle("Dark mode")
+	if post == nil {
+		t.Fatal("post 'Dark mode' is not created")
+	}
+	if post.BoardID != testBoard || post.Details != "Please add dark mode" {
+		t.Errorf("unexpected post %+v", post.CreatePost)
+	}
+	if got := len(env.canny.PostComments(post.ID)); got != 3 {
+		t.Errorf("post has %d comments, want 3", got)
+	}
+	if got := len(env.canny.PostVotes(post.ID)); got != 2 {
+		t.Errorf("post has %d votes, want 2", got)
+	}
+	orphan := env.canny.PostByTitle("Orphan idea")
+	if orphan == nil || orphan.AuthorID != env.adminID {
+		t.Errorf("post of missing user must be created by default user, got %+v", orphan)
+	}
+	if m.getIDFromState(testTopic, "post", "1") != post.ID {
+		t.Errorf("post is not saved to state")
+	}
+}
+
+func TestMigrateOrder(t *testing.T) {
+	const bugsTopic, bugsBoard = "200-Bugs", "board2"
+	env := newTestEnv(t)
+	env.zendesk.AddPost(bugsTopic, &zendesk.Post{ID: 4, Title: "Crash on start", Details: "It crashes", AuthorID: 13}, nil, nil)
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	m.Topics = []TopicMapping{{Topic: bugsTopic, Board: bugsBoard}, {Topic: testTopic, Board: testBoard}}
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	env.canny.Lock()
+	defer env.canny.Unlock()
+	var titles []string
+	for _, post := range env.canny.Posts {
+		titles = append(titles, post.Title)
+	}
+	want := []string{"Crash on start", "Dark mode", "Export to CSV", "Orphan idea"}
+	if !reflect.DeepEqual(titles, want) {
+		t.Errorf("posts are created in order %v, want %v", titles, want)
+	}
+}
+
+func TestMigrateFilter(t *testing.T) {
+	env := newTestEnv(t)
+	var logs bytes.Buffer
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	m.Logger = log.New(&logs, "", 0)
+	m.Source.(*zendesk.Client).Filter = &zendesk.Filter{ExcludeIDs: map[int64]bool{1: true}}
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	env.assertCounts(t, 2, 0, 1)
+	for _, req := range env.zendesk.Requests() {
+		if strings.HasPrefix(req, "/api/v2/community/posts/1/") {
+			t.Errorf("details of a filtered out post must not be loaded, got %s", req)
+		}
+	}
+	if !strings.Contains(logs.String(), "Post 'Dark mode' (1) is filtered out") ||
+		!strings.Contains(logs.String(), "1 filtered out") {
+		t.Errorf("filtered out post is not reported:\n%s", logs.String())
+	}
+}
+
+func TestMigrateTemplates(t *testing.T) {
+	env := newTestEnv(t)
+	detailsFile := filepath.Join(t.TempDir(), "details.tmpl")
+	if err := ioutil.WriteFile(detailsFile, []byte("{{.Details}}\n{{.Upvotes}} votes{{if .DefaultUser}}, by {{.Author.Name}}{{end}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	templates, err := ParseTemplates("[{{.Topic}}] {{.Title}}", "@"+detailsFile, "{{.Body}}{{if .DefaultUser}} ({{.AuthorName}}){{end}}")
+	if err != nil {
+		t.Fatalf("ParseTemplates: %v", err)
+	}
+	//the author without email is created by the default user
+	env.zendesk.AddUsers(&zendesk.User{ID: 14, Name: "Dee"})
+	env.zendesk.AddPost(testTopic, &zendesk.Post{ID: 4, Title: "Offline mode", Details: "Work offline", AuthorID: 14}, nil, nil)
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	m.UserRules.NoEmail = NoEmailDefault
+	m.Templates = templates
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	post := env.canny.PostByTitle("[100-Ideas] Dark mode")
+	if post == nil || post.Details != "Please add dark mode\n2 votes" {
+		t.Fatalf("unexpected post %+v", post)
+	}
+	if orphan := env.canny.PostByTitle("[100-Ideas] Orphan idea"); orphan == nil ||
+		orphan.Details != "Author is deleted\n0 votes, by an unknown user" {
+		t.Errorf("unexpected post of missing user %+v", orphan)
+	}
+	if offline := env.canny.PostByTitle("[100-Ideas] Offline mode"); offline == nil ||
+		offline.Details != "Work offline\n0 votes, by Dee" || offline.AuthorID != env.adminID {
+		t.Errorf("unexpected post of user without email %+v", offline)
+	}
+	values := make(map[string]bool)
+	for _, comment := range env.canny.PostComments(post.ID) {
+		values[comment.Value] = true
+	}
+	if !values["Yes"] || !values["Me too (an unknown user)"] {
+		t.Errorf("unexpected comments %v", values)
+	}
+
+	//DefaultUser of a post found in state is set for its new comments
+	env.zendesk.Lock()
+	env.zendesk.Comments[3] = append(env.zendesk.Comments[3], &zendesk.Comment{ID: 301, Body: "Still wanted", AuthorID: 12})
+	for _, p := range env.zendesk.Posts[testTopic] {
+		if p.ID == 3 {
+			p.CommentCount = 1
+		}
+	}
+	env.zendesk.Unlock()
+	if m.Templates, err = ParseTemplates("", "", "{{.Body}}{{if .Post.DefaultUser}} on a post of {{.Post.Author.Name}}{{end}}"); err != nil {
+		t.Fatalf("ParseTemplates: %v", err)
+	}
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	orphan := env.canny.PostByTitle("[100-Ideas] Orphan idea")
+	if comments := env.canny.PostComments(orphan.ID); len(comments) != 1 || comments[0].Value != "Still wanted on a post of an unknown user" {
+		t.Errorf("unexpected comments of post in state %+v", comments)
+	}
+
+	//nil authors are never passed to templates, so the check of fields of .Author passes
+	if _, err := ParseTemplates("{{.Author.Name}}: {{.Title}}", "", "{{.Comment.Author.Email}} {{.Post.Author.Name}}"); err != nil {
+		t.Errorf("template with author fields is not parsed: %v", err)
+	}
+	if _, err := ParseTemplates("{{.Title", "", ""); err == nil {
+		t.Error("invalid template must not be parsed")
+	}
+	if _, err := ParseTemplates("", "{{.Details}} by {{.Author.Nmae}}", ""); err == nil {
+		t.Error("template with unknown field must not be parsed")
+	}
+	if _, err := ParseTemplates("", "", "{{.Body}} on {{.Post.Titel}}"); err == nil {
+		t.Error("comment template with unknown field must not be parsed")
+	}
+}
+
+func TestMigrateResumeFromState(t *testing.T) {
+	env := newTestEnv(t)
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("first Migrate: %v", err)
+	}
+	m = env.migration()
+	m.DefaultUserID = env.adminID
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	env.assertCounts(t, 3, 3, 3)
+}
+
+func TestMigrateMissingUserWithoutDefault(t *testing.T) {
+	env := newTestEnv(t)
+	if err := env.migration().Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	//post 3 and comment 103 of the missing user are skipped, everything else is migrated
+	env.assertCounts(t, 2, 2, 3)
+	if env.canny.PostByTitle("Orphan idea") != nil {
+		t.Error("post of missing user must not be created without default user")
+	}
+}
+
+func TestMigratePartialFailure(t *testing.T) {
+	env := newTestEnv(t)
+	env.canny.Fail = func(endpoint string, params map[string]interface{}) int {
+		if endpoint == "comments/create" && params["value"] == "Agree" {
+			return 500
+		}
+		return 0
+	}
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	//the failed comment doesn't stop the comment and votes after it
+	env.assertCounts(t, 3, 2, 3)
+	if m.getIDFromState(testTopic, "comment", "102") != "" {
+		t.Error("failed comment must not be saved to state")
+	}
+
+	env.canny.Lock()
+	env.canny.Fail = nil
+	env.canny.Unlock()
+	m = env.migration()
+	m.DefaultUserID = env.adminID
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	env.assertCounts(t, 3, 3, 3)
+}
+
+func TestMigrateZendeskFailure(t *testing.T) {
+	env := newTestEnv(t)
+	env.zendesk.Fail = func(r *http.Request) int {
+		if r.URL.Path == "/api/v2/community/posts/1/votes.json" {
+			return 503
+		}
+		return 0
+	}
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	//post 1 is migrated without votes and queued for retry
+	env.assertCounts(t, 3, 3, 1)
+	if item := m.state.Retry[testTopic]["1"]; item == nil || !item.Votes || item.Comments {
+		t.Fatalf("post 1 must be queued for votes retry, got %+v", m.state.Retry)
+	}
+
+	env.zendesk.Lock()
+	env.zendesk.Fail = nil
+	env.zendesk.Unlock()
+	loaded := len(env.zendesk.Requests())
+	m = env.migration()
+	m.DefaultUserID = env.adminID
+	m.RetryFailed = true
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("retry Migrate: %v", err)
+	}
+	env.assertCounts(t, 3, 3, 3)
+	if len(m.state.Retry) != 0 {
+		t.Errorf("retry queue must be empty, got %+v", m.state.Retry)
+	}
+	for _, req := range env.zendesk.Requests()[loaded:] {
+		if strings.Contains(req, "/posts/2") || strings.Contains(req, "/posts/3") {
+			t.Errorf("only queued posts must be loaded on retry, got %s", req)
+		}
+		if strings.Contains(req, "/posts/1/comments") {
+			t.Errorf("only queued votes must be loaded on retry, got %s", req)
+		}
+	}
+}
+
+func TestMigrateRetryFailedObjects(t *testing.T) {
+	env := newTestEnv(t)
+	env.zendesk.Fail = func(r *http.Request) int {
+		if r.URL.Path == "/api/v2/community/posts/1/votes.json" {
+			return 503
+		}
+		return 0
+	}
+	env.canny.Fail = func(endpoint string, params map[string]interface{}) int {
+		if endpoint == "comments/create" && params["value"] == "Agree" {
+			return 500
+		}
+		return 0
+	}
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	//the incomplete post is queued with the failed comment, although the post has errors
+	item := m.state.Retry[testTopic]["1"]
+	if item == nil || !item.Votes || item.Comments || len(item.CommentIDs) != 1 || item.CommentIDs[0] != "102" {
+		t.Fatalf("post 1 must be queued for votes and comment 102 retry, got %+v", item)
+	}
+
+	env.zendesk.Lock()
+	env.zendesk.Fail = nil
+	env.zendesk.Unlock()
+	env.canny.Lock()
+	env.canny.Fail = nil
+	env.canny.Unlock()
+	m = env.migration()
+	m.DefaultUserID = env.adminID
+	m.RetryFailed = true
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("retry Migrate: %v", err)
+	}
+	env.assertCounts(t, 3, 3, 3)
+	if len(m.state.Retry) != 0 {
+		t.Errorf("retry queue must be empty, got %+v", m.state.Retry)
+	}
+}
+
+func TestMigrateInterrupted(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	env.canny.Fail = func(endpoint string, params map[string]interface{}) int {
+		if endpoint == "votes/create" {
+			cancel()
+		}
+		return 0
+	}
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	err := m.Migrate(ctx)
+	if _, ok := err.(*InterruptedError); !ok {
+		t.Fatalf("Migrate must return *InterruptedError, got %v", err)
+	}
+	env.canny.Lock()
+	env.canny.Fail = nil
+	env.canny.Unlock()
+	m = env.migration()
+	m.DefaultUserID = env.adminID
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	env.assertCounts(t, 3, 3, 3)
+}
+
+func TestMigrateToFiles(t *testing.T) {
+	env := newTestEnv(t)
+	dir := t.TempDir()
+	m := env.migration()
+	m.Destination = &destination.Files{Dir: dir}
+	m.DefaultUserID = "admin"
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, testBoard, "*.md"))
+	if len(files) != 3 {
+		t.Fatalf("%d post files written, want 3", len(files))
+	}
+	md, err := ioutil.ReadFile(filepath.Join(dir, testBoard, "dark-mode.md"))
+	if err != nil {
+		t.Fatalf("post file is not written: %v", err)
+	}
+	if !strings.Contains(string(md), "# Dark mode") || !strings.Contains(string(md), "2 votes · 3 comments") {
+		t.Errorf("unexpected post file:\n%s", md)
+	}
+}
+
+func TestMigrateReusesUsersFromState(t *testing.T) {
+	env := newTestEnv(t)
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("first Migrate: %v", err)
+	}
+	env.canny.Lock()
+	users := len(env.canny.Users)
+	env.canny.Unlock()
+	requests := len(env.zendesk.Requests())
+
+	env.zendesk.AddPost(testTopic, &zendesk.Post{ID: 4, Title: "Search", AuthorID: 13}, nil, []*zendesk.Vote{{ID: 204, UserID: 11}})
+	m = env.migration()
+	m.DefaultUserID = env.adminID
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	env.assertCounts(t, 4, 3, 4)
+	env.canny.Lock()
+	defer env.canny.Unlock()
+	if len(env.canny.Users) != users {
+		t.Errorf("%d users are created on the second run, want 0", len(env.canny.Users)-users)
+	}
+	for _, req := range env.zendesk.Requests()[requests:] {
+		if strings.Contains(req, "show_many") && !strings.Contains(req, "ids=99") {
+			t.Errorf("known users are loaded again: %s", req)
+		}
+	}
+}
+
+func TestMigrateUserRules(t *testing.T) {
+	env := newTestEnv(t)
+	env.zendesk.AddUsers(
+		&zendesk.User{ID: 14, Name: "Ann Duplicate", Email: "Ann@example.com"},
+		&zendesk.User{ID: 15, Name: "Nobody"},
+		&zendesk.User{ID: 16, Name: "Sue", Email: "sue@staff.example.com"},
+	)
+	env.zendesk.AddPost(testTopic, &zendesk.Post{ID: 5, Title: "Merged author", AuthorID: 14},
+		[]*zendesk.Comment{{ID: 105, Body: "Staff reply", AuthorID: 16}},
+		[]*zendesk.Vote{{ID: 205, UserID: 15}, {ID: 206, UserID: 16}})
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	m.UserRules = UserRules{
+		EmailMapping: map[string]string{"@staff.example.com": env.adminID},
+		MergeByEmail: true,
+		NoEmail:      NoEmailSkip,
+	}
+	//post 1 of Ann is migrated before post 5 of her duplicate
+	m.Source.(*zendesk.Client).Parallel = 1
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	ann := env.canny.PostByTitle("Dark mode")
+	merged := env.canny.PostByTitle("Merged author")
+	if ann == nil || merged == nil {
+		t.Fatal("posts are not migrated")
+	}
+	if merged.AuthorID != ann.AuthorID {
+		t.Errorf("users with the same email must be merged, got %s and %s", merged.AuthorID, ann.AuthorID)
+	}
+	comments := env.canny.PostComments(merged.ID)
+	if len(comments) != 1 || comments[0].AuthorID != env.adminID {
+		t.Errorf("staff comment must be authored by admin, got %+v", comments)
+	}
+	votes := env.canny.PostVotes(merged.ID)
+	if len(votes) != 1 || votes[0].VoterID != env.adminID {
+		t.Errorf("vote of user without email must be skipped, got %+v", votes)
+	}
+}
+
+func TestMigrateMapAgents(t *testing.T) {
+	env := newTestEnv(t)
+	fallbackID := env.canny.AddAdmin(canny.FindOrCreateUser{Name: "Support", Email: "support@example.com"})
+	env.zendesk.AddUsers(
+		&zendesk.User{ID: 17, Name: "Agent Admin", Email: "Admin@example.com", Role: "agent"},
+		&zendesk.User{ID: 18, Name: "Agent Zed", Email: "zed@example.com", Role: "admin"},
+	)
+	env.zendesk.AddPost(testTopic, &zendesk.Post{ID: 6, Title: "Agent idea", AuthorID: 17},
+		[]*zendesk.Comment{{ID: 106, Body: "Other agent", AuthorID: 18}}, nil)
+	m := env.migration()
+	m.UserRules.MapAgents = true
+	m.UserRules.AgentFallback = fallbackID
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	post := env.canny.PostByTitle("Agent idea")
+	if post == nil || post.AuthorID != env.adminID {
+		t.Fatalf("agent must be mapped to admin by email, got %+v", post)
+	}
+	comments := env.canny.PostComments(post.ID)
+	if len(comments) != 1 || comments[0].AuthorID != fallbackID {
+		t.Errorf("agent without Canny admin must be mapped to fallback admin, got %+v", comments)
+	}
+	if user := m.state.Users["17"]; user == nil || user.Role != "agent" || user.CannyID != "" {
+		t.Errorf("mapped agent must be saved without Canny ID, got %+v", user)
+	}
+}
+
+func TestMigrateUserFields(t *testing.T) {
+	env := newTestEnv(t)
+	env.zendesk.AddUsers(&zendesk.User{
+		ID: 19, Name: "Dee", Email: "dee@example.com", Locale: "de", TimeZone: "Berlin", OrganizationID: 7,
+		Photo:      &zendesk.Attachment{ContentURL: "https://example.zendesk.com/photos/dee.png"},
+		UserFields: map[string]interface{}{"plan": "enterprise"},
+	})
+	env.zendesk.AddPost(testTopic, &zendesk.Post{ID: 7, Title: "Fields", AuthorID: 19}, nil, nil)
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	m.UserRules.CustomFields = map[string]string{"locale": "locale", "plan": "zendeskPlan", "time_zone": "timeZone"}
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	env.canny.Lock()
+	defer env.canny.Unlock()
+	var dee *cannytest.User
+	for _, user := range env.canny.Users {
+		if user.Email == "dee@example.com" {
+			dee = user
+		}
+	}
+	if dee == nil {
+		t.Fatal("user is not created")
+	}
+	if dee.AvatarURL != "https://example.zendesk.com/photos/dee.png" {
+		t.Errorf("avatar is not sent, got %q", dee.AvatarURL)
+	}
+	expected := map[string]interface{}{"locale": "de", "zendeskPlan": "enterprise", "timeZone": "Berlin"}
+	if !reflect.DeepEqual(dee.CustomFields, expected) {
+		t.Errorf("custom fields: expected %v, got %v", expected, dee.CustomFields)
+	}
+}
+
+func TestMigrateCompanies(t *testing.T) {
+	env := newTestEnv(t)
+	env.zendesk.AddOrganizations(&zendesk.Organization{ID: 7, Name: "Acme", OrganizationFields: map[string]interface{}{"mrr": "120.5"}})
+	env.zendesk.AddUsers(
+		&zendesk.User{ID: 20, Name: "Eve", Email: "eve@acme.example.com", OrganizationID: 7},
+		&zendesk.User{ID: 21, Name: "Fay", Email: "fay@acme.example.com", OrganizationID: 7},
+	)
+	env.zendesk.AddPost(testTopic, &zendesk.Post{ID: 8, Title: "Company idea", AuthorID: 20},
+		nil, []*zendesk.Vote{{ID: 208, UserID: 21}})
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	m.Source.(*zendesk.Client).LoadOrganizations = true
+	m.Source.(*zendesk.Client).Parallel = 1
+	m.UserRules.Companies = true
+	m.UserRules.MonthlySpendField = "mrr"
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	env.canny.Lock()
+	defer env.canny.Unlock()
+	expected := []canny.Company{{ID: "7", Name: "Acme", MonthlySpend: 120.5}}
+	var found int
+	for _, user := range env.canny.Users {
+		if strings.HasSuffix(user.Email, "@acme.example.com") {
+			found++
+			if !reflect.DeepEqual(user.Companies, expected) {
+				t.Errorf("companies of %s: expected %+v, got %+v", user.Email, expected, user.Companies)
+			}
+		} else if len(user.Companies) > 0 {
+			t.Errorf("user %s without organization has companies %+v", user.Email, user.Companies)
+		}
+	}
+	if found != 2 {
+		t.Errorf("expected 2 users with companies, got %d", found)
+	}
+	var orgRequests int
+	for _, req := range env.zendesk.Requests() {
+		if strings.Contains(req, "organizations/show_many") {
+			orgRequests++
+		}
+	}
+	if orgRequests != 1 {
+		t.Errorf("organization must be loaded once, got %d requests", orgRequests)
+	}
+}
+
+func TestMigrateLabels(t *testing.T) {
+	env := newTestEnv(t)
+	env.zendesk.Topics[testTopic] = &zendesk.Topic{ID: 100, Name: "Ideas"}
+	env.zendesk.ContentTags["01A"] = &zendesk.ContentTag{ID: "01A", Name: "Mobile"}
+	env.zendesk.AddPost(testTopic, &zendesk.Post{ID: 9, Title: "Tagged idea", AuthorID: 11, Featured: true, ContentTagIDs: []string{"01A", "01B"}}, nil, nil)
+	existingID := env.canny.AddTag(testBoard, "Ideas")
+	labels, err := ParsePostLabels([]string{"topic", "content-tags", "flags"}, "content-tag")
+	if err != nil {
+		t.Fatalf("ParsePostLabels: %v", err)
+	}
+	for run := 0; run < 2; run++ {
+		m := env.migration()
+		m.DefaultUserID = env.adminID
+		m.Source.(*zendesk.Client).LoadTopicName = true
+		m.Source.(*zendesk.Client).LoadContentTags = true
+		m.Labels = labels
+		if err := m.Migrate(context.Background()); err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+	}
+	tagged := env.canny.PostByTitle("Tagged idea")
+	if tagged == nil {
+		t.Fatal("post is not migrated")
+	}
+	if tags := env.canny.PostTags(tagged.ID); !reflect.DeepEqual(tags, []string{"Ideas", "Mobile", "featured"}) {
+		t.Errorf("unexpected tags %v", tags)
+	}
+	if name := env.canny.CategoryName(tagged.CategoryID); name != "Mobile" {
+		t.Errorf("post must be in category Mobile, got %q", name)
+	}
+	if tags := env.canny.PostTags(env.canny.PostByTitle("Dark mode").ID); !reflect.DeepEqual(tags, []string{"Ideas"}) {
+		t.Errorf("unexpected tags %v", tags)
+	}
+	env.canny.Lock()
+	defer env.canny.Unlock()
+	if len(env.canny.Tags) != 3 || env.canny.Tags[0].ID != existingID {
+		t.Errorf("tags must be created once and the existing tag reused, got %d tags", len(env.canny.Tags))
+	}
+	if len(env.canny.Categories) != 1 {
+		t.Errorf("category must be created once, got %d", len(env.canny.Categories))
+	}
+}
+
+func TestMigratePostFields(t *testing.T) {
+	env := newTestEnv(t)
+	created := time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC)
+	env.zendesk.AddPost(testTopic, &zendesk.Post{ID: 10, Title: "Traced idea", AuthorID: 11, HTMLURL: "https://example.zendesk.com/posts/10",
+		VoteSum: 5, CreatedAt: created}, nil, nil)
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	m.PostFields = map[string]string{"url": "zendeskURL", "id": "zendeskID", "vote_sum": "zendeskVotes", "topic": "zendeskTopic", "created_at": "zendeskCreated"}
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	post := env.canny.PostByTitle("Traced idea")
+	if post == nil {
+		t.Fatal("post is not migrated")
+	}
+	expected := map[string]interface{}{
+		"zendeskURL":     "https://example.zendesk.com/posts/10",
+		"zendeskID":      "10",
+		"zendeskVotes":   float64(5),
+		"zendeskTopic":   testTopic,
+		"zendeskCreated": "2020-02-03T04:05:06Z",
+	}
+	if !reflect.DeepEqual(post.CustomFields, expected) {
+		t.Errorf("custom fields: expected %v, got %v", expected, post.CustomFields)
+	}
+}
+
+func TestMigrateDownvotes(t *testing.T) {
+	for _, policy := range []DownvotePolicy{DownvoteSkip, DownvoteComment, DownvoteField} {
+		t.Run(string(policy), func(t *testing.T) {
+			env := newTestEnv(t)
+			env.zendesk.AddPost(testTopic, &zendesk.Post{ID: 11, Title: "Disputed idea", AuthorID: 11, VoteCount: 3, VoteSum: 1},
+				nil, []*zendesk.Vote{{ID: 211, UserID: 11, Value: 1}, {ID: 212, UserID: 12, Value: -1}, {ID: 213, UserID: 13, Value: 1}})
+			var logs bytes.Buffer
+			m := env.migration()
+			m.DefaultUserID = env.adminID
+			m.Logger = log.New(&logs, "", 0)
+			m.Downvotes = policy
+			if err := m.Migrate(context.Background()); err != nil {
+				t.Fatalf("Migrate: %v", err)
+			}
+			post := env.canny.PostByTitle("Disputed idea")
+			if post == nil {
+				t.Fatal("post is not migrated")
+			}
+			if votes := env.canny.PostVotes(post.ID); len(votes) != 2 {
+				t.Errorf("only upvotes must be migrated, got %d votes", len(votes))
+			}
+			comments := env.canny.PostComments(post.ID)
+			if policy == DownvoteComment {
+				if len(comments) != 1 || !comments[0].Internal {
+					t.Errorf("downvote must be an internal comment, got %+v", comments)
+				}
+			} else if len(comments) != 0 {
+				t.Errorf("unexpected comments %+v", comments)
+			}
+			if policy == DownvoteField {
+				if downvotes := post.CustomFields[defaultDownvotesField]; downvotes != float64(1) {
+					t.Errorf("downvotes field must be 1, got %v", downvotes)
+				}
+			}
+			//skipped downvotes are not a difference
+			if strings.Contains(logs.String(), "Votes of post 'Disputed idea' (11) differ") {
+				t.Errorf("votes of the post must not differ:\n%s", logs.String())
+			}
+		})
+	}
+}
+
+func TestMigrateVoteDifferences(t *testing.T) {
+	env := newTestEnv(t)
+	//an upvote is lost by Zendesk, the vote of the missing user 99 is not migrated
+	env.zendesk.AddPost(testTopic, &zendesk.Post{ID: 11, Title: "Lost votes", AuthorID: 11, VoteCount: 3, VoteSum: 3},
+		nil, []*zendesk.Vote{{ID: 211, UserID: 12, Value: 1}, {ID: 212, UserID: 99, Value: 1}})
+	//votes of a post which is not created are not reconciled
+	env.canny.Fail = func(endpoint string, params map[string]interface{}) int {
+		if endpoint == "posts/create" && params["title"] == "Export to CSV" {
+			return 500
+		}
+		return 0
+	}
+	var logs bytes.Buffer
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	m.Logger = log.New(&logs, "", 0)
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if !strings.Contains(logs.String(), "Votes of post 'Lost votes' (11) differ: 3 votes reported, 2 loaded; net score 3 reported, 2 loaded (2 up, 0 down); 2 upvotes loaded, 1 migrated") {
+		t.Errorf("vote differences are not reported:\n%s", logs.String())
+	}
+	if strings.Contains(logs.String(), "Votes of post 'Dark mode'") {
+		t.Errorf("votes of posts migrated completely must not differ:\n%s", logs.String())
+	}
+	if strings.Contains(logs.String(), "Votes of post 'Export to CSV'") {
+		t.Errorf("votes of a failed post must not be reconciled:\n%s", logs.String())
+	}
+}
+
+func TestMigrateAnonymize(t *testing.T) {
+	env := newTestEnv(t)
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	m.UserRules.Anonymize = true
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	env.assertCounts(t, 3, 3, 3)
+	env.canny.Lock()
+	defer env.canny.Unlock()
+	for _, user := range env.canny.Users[1:] {
+		if user.Email != "" || !strings.HasPrefix(user.Name, "User ") {
+			t.Errorf("user is not anonymized: %+v", user.FindOrCreateUser)
+		}
+	}
+	//default templates don't add user names
+	texts := make([]string, 0)
+	for _, post := range env.canny.Posts {
+		texts = append(texts, post.Details)
+	}
+	for _, comment := range env.canny.Comments {
+		texts = append(texts, comment.Value)
+	}
+	for _, text := range texts {
+		for _, name := range []string{"Ann", "Bob", "Cid"} {
+			if strings.Contains(text, name) {
+				t.Errorf("real name %s is posted: '%s'", name, text)
+			}
+		}
+	}
+}
+
+func TestMigrateAnonymizeTemplates(t *testing.T) {
+	env := newTestEnv(t)
+	templates, err := ParseTemplates("", "{{.Details}} by {{.AuthorName}}{{with .Author}} {{.Email}}{{end}}",
+		"{{.Body}} by {{.Comment.Author.Name}} {{.Comment.Author.Email}} on {{.Post.Post.Author.Name}}")
+	if err != nil {
+		t.Fatalf("ParseTemplates: %v", err)
+	}
+	m := env.migration()
+	m.DefaultUserID = env.adminID
+	m.UserRules.Anonymize = true
+	m.Templates = templates
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	post := env.canny.PostByTitle("Dark mode")
+	if post == nil || !strings.HasPrefix(post.Details, "Please add dark mode by User ") ||
+		strings.Contains(post.Details, "Ann") || strings.Contains(post.Details, "@") {
+		t.Errorf("author of the post is not anonymized: %+v", post)
+	}
+	for _, comment := range env.canny.PostComments(post.ID) {
+		if strings.Contains(comment.Value, "Ann") || strings.Contains(comment.Value, "Bob") || strings.Contains(comment.Value, "@") {
+			t.Errorf("users of the comment are not anonymized: %q", comment.Value)
+		}
+	}
+}
+
+func TestLoadTemplatesConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	if err := ioutil.WriteFile(path, []byte(`{"title": "[{{.Topic}}] {{.Title}}", "comment": "{{.Body}}"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadTemplatesConfig(path)
+	if err != nil {
+		t.Fatalf("LoadTemplatesConfig: %v", err)
+	}
+	if config.Title != "[{{.Topic}}] {{.Title}}" || config.Details != "" || config.Comment != "{{.Body}}" {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if err := ioutil.WriteFile(path, []byte(`{"title": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTemplatesConfig(path); err == nil {
+		t.Error("invalid config must not be loaded")
+	}
+}
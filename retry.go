@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
+)
+
+//retryTopic migrates only posts of the topic queued for retry because their comments or votes were not loaded or
+//failed to migrate. Only queued comments or votes of a post are loaded again, objects already migrated are skipped as usual.
+//It returns number of completed posts
+func (s *Migration) retryTopic(ctx context.Context, loader feedback.PostLoader, topic, cBoard string) int {
+	var completed, incomplete, fail int
+	ids := s.state.retryQueue(topic)
+	s.Logger.Printf("Retrying %d posts of topic '%s' in board '%s'", len(ids), topic, cBoard)
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		post, err := loader.Post(ctx, topic, id, s.state.Retry[topic][id].parts())
+		if err != nil {
+			if ctx.Err() == nil {
+				s.Logger.Printf("\tError while loading post %s: %v", id, err)
+				fail++
+			}
+			continue
+		}
+		s.printPost(post)
+		err = s.migratePost(ctx, post, topic, cBoard)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil && err != errUserSkipped {
+			s.Logger.Printf("\tError while migrating post '%s' (%s): %v", post.Title, post.ID, err)
+			if s.getIDFromState(topic, "post", post.ID) == "" {
+				fail++
+				continue
+			}
+		}
+		if s.state.queueRetry(topic, post, err) {
+			s.Logger.Printf("\tComments or votes of post '%s' (%s) are still incomplete or failed", post.Title, post.ID)
+			incomplete++
+		} else {
+			if s.Verbose {
+				s.Logger.Printf("\tCompleted post '%s'", post.Title)
+			}
+			completed++
+		}
+	}
+	s.Logger.Printf("Retried topic '%s': %d posts completed, %d still incomplete, %d errors", topic, completed, incomplete, fail)
+	return completed
+}
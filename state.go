@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
+	"io/ioutil"
+	"sort"
+)
+
+const stateVersion = 2
+
+//State is saved to the state file between runs. It contains IDs of migrated objects and known users
+type State struct {
+	Version int                          `json:"version"`
+	Topics  map[string]map[string]string `json:"topics"` // contains mapping of [source_topic: ['<source_type>_<source_id>':'canny_id']]
+	Users   map[string]*UserState        `json:"users"`  // by source user ID
+	//Labels contains Canny tag and category IDs by '<tag|category>_<board_id>_<name>'
+	Labels map[string]string `json:"labels,omitempty"`
+	//Retry contains posts with incomplete comments or votes to retry, by source topic and source post ID
+	Retry map[string]map[string]*RetryItem `json:"retry,omitempty"`
+}
+
+//RetryItem describes what was not loaded or migrated for a post
+type RetryItem struct {
+	Title      string   `json:"title"`
+	Comments   bool     `json:"comments,omitempty"`    // comments are not loaded completely
+	Votes      bool     `json:"votes,omitempty"`       // votes are not loaded completely
+	CommentIDs []string `json:"comment_ids,omitempty"` // comments failed to migrate
+	VoteIDs    []string `json:"vote_ids,omitempty"`    // votes failed to migrate
+}
+
+//parts returns parts of the post to load to retry the item
+func (i *RetryItem) parts() feedback.PostParts {
+	return feedback.PostParts{
+		Comments: i.Comments || len(i.CommentIDs) > 0,
+		Votes:    i.Votes || len(i.VoteIDs) > 0,
+	}
+}
+
+//UserState contains source user details and the Canny user it is mapped to.
+//Empty CannyID means the user is found or created in Canny by email or external ID
+type UserState struct {
+	feedback.User
+	CannyID string `json:"canny_id,omitempty"`
+}
+
+func newState() *State {
+	return &State{
+		Version: stateVersion,
+		Topics:  make(map[string]map[string]string),
+		Users:   make(map[string]*UserState),
+		Labels:  make(map[string]string),
+		Retry:   make(map[string]map[string]*RetryItem),
+	}
+}
+
+//loadState reads state file. A missing file is an empty state. State files of version 1, which
+//contain only topics mapping, are converted
+func loadState(file string) (*State, error) {
+	state := newState()
+	if file == "" || !fileExists(file) {
+		return state, nil
+	}
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var version struct {
+		Version int `json:"version"`
+	}
+	_ = json.Unmarshal(raw, &version)
+	switch version.Version {
+	case 0:
+		err = json.Unmarshal(raw, &state.Topics)
+	case stateVersion:
+		err = json.Unmarshal(raw, state)
+	default:
+		return nil, fmt.Errorf("unsupported state version %d", version.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if state.Topics == nil {
+		state.Topics = make(map[string]map[string]string)
+	}
+	if state.Users == nil {
+		state.Users = make(map[string]*UserState)
+	}
+	if state.Labels == nil {
+		state.Labels = make(map[string]string)
+	}
+	if state.Retry == nil {
+		state.Retry = make(map[string]map[string]*RetryItem)
+	}
+	return state, nil
+}
+
+func (s *State) save(file string) error {
+	data, err := json.MarshalIndent(s, "", " ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+func (s *State) getID(topic string, objType string, id string) string {
+	if s.Topics[topic] == nil {
+		return ""
+	}
+	return s.Topics[topic][formatKey(objType, id)]
+}
+
+func (s *State) saveID(topic string, objType string, id string, cannyID string) {
+	if s.Topics[topic] == nil {
+		s.Topics[topic] = make(map[string]string)
+	}
+	s.Topics[topic][formatKey(objType, id)] = cannyID
+}
+
+//saveUser saves user details, and the Canny user ID if it is not empty
+func (s *State) saveUser(user *feedback.User, cannyID string) {
+	userState := s.Users[user.ID]
+	if userState == nil {
+		userState = &UserState{}
+		s.Users[user.ID] = userState
+	}
+	userState.User = *user
+	if cannyID != "" {
+		userState.CannyID = cannyID
+	}
+}
+
+//knownUsers returns details of all users saved in state
+func (s *State) knownUsers() []*feedback.User {
+	users := make([]*feedback.User, 0, len(s.Users))
+	for id, userState := range s.Users {
+		user := userState.User
+		user.ID = id
+		users = append(users, &user)
+	}
+	return users
+}
+
+//getLabel returns ID of a tag or category of the board by its name
+func (s *State) getLabel(kind, boardID, name string) string {
+	return s.Labels[formatKey(kind, formatKey(boardID, name))]
+}
+
+func (s *State) saveLabel(kind, boardID, name, id string) {
+	s.Labels[formatKey(kind, formatKey(boardID, name))] = id
+}
+
+//queueRetry adds the post to the retry queue if its comments or votes are not loaded completely, or failed to migrate
+//with err returned by migratePost. Otherwise the post is removed from the queue. It reports whether the post is queued
+func (s *State) queueRetry(topic string, post *feedback.Post, err error) bool {
+	item := &RetryItem{Title: post.Title, Comments: post.CommentsIncomplete, Votes: post.VotesIncomplete}
+	var errs errorList
+	if errors.As(err, &errs) {
+		for _, err := range errs {
+			var objErr *objectError
+			if !errors.As(err, &objErr) {
+				continue
+			}
+			if objErr.objType == "comment" {
+				item.CommentIDs = append(item.CommentIDs, objErr.id)
+			} else {
+				item.VoteIDs = append(item.VoteIDs, objErr.id)
+			}
+		}
+	}
+	if !item.Comments && !item.Votes && len(item.CommentIDs) == 0 && len(item.VoteIDs) == 0 {
+		if s.Retry[topic] != nil {
+			delete(s.Retry[topic], post.ID)
+			if len(s.Retry[topic]) == 0 {
+				delete(s.Retry, topic)
+			}
+		}
+		return false
+	}
+	if s.Retry[topic] == nil {
+		s.Retry[topic] = make(map[string]*RetryItem)
+	}
+	s.Retry[topic][post.ID] = item
+	return true
+}
+
+//retryQueue returns sorted IDs of posts of the topic to retry
+func (s *State) retryQueue(topic string) []string {
+	ids := make([]string, 0, len(s.Retry[topic]))
+	for id := range s.Retry[topic] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func formatKey(objType string, id string) string {
+	return fmt.Sprintf("%s_%s", objType, id)
+}
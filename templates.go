@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//Default templates of Canny post title, details and comment body, the source text as is
+const (
+	DefaultTitleTemplate   = `{{.Title}}`
+	DefaultDetailsTemplate = `{{.Details}}`
+	DefaultCommentTemplate = `{{.Body}}`
+)
+
+//unknownAuthorName is AuthorName of posts and comments without a user, e.g. deleted
+const unknownAuthorName = "an unknown user"
+
+//TemplatesConfig contains text of templates, as they are set in a templates config file. Empty template means the default one
+type TemplatesConfig struct {
+	Title   string `json:"title"`
+	Details string `json:"details"`
+	Comment string `json:"comment"`
+}
+
+//LoadTemplatesConfig reads templates config from a JSON file
+func LoadTemplatesConfig(path string) (TemplatesConfig, error) {
+	var config TemplatesConfig
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("cannot read templates config:%w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid templates config %s:%w", path, err)
+	}
+	return config, nil
+}
+
+var defaultTemplates = Templates{
+	Title:   template.Must(template.New("title").Parse(DefaultTitleTemplate)),
+	Details: template.Must(template.New("details").Parse(DefaultDetailsTemplate)),
+	Comment: template.Must(template.New("comment").Parse(DefaultCommentTemplate)),
+}
+
+//Templates are text/template templates of Canny post title, post details and comment body.
+//Post templates are executed with PostData, the comment template with CommentData. Nil template means the default one
+type Templates struct {
+	Title   *template.Template
+	Details *template.Template
+	Comment *template.Template
+}
+
+//PostData is available to post templates. Authors are never nil, an unknown author has empty ID and
+//"an unknown user" name. Users of Post are anonymized if users are anonymized
+type PostData struct {
+	Post        *feedback.Post
+	Title       string // title as plain text
+	Details     string // details as plain text
+	URL         string
+	Date        string // created date as YYYY-MM-DD, empty if unknown
+	Author      *feedback.User
+	AuthorName  string // author name, or "an unknown user" if the author is unknown
+	DefaultUser bool   // the post is created by the default user, e.g. the author is unknown or has no email
+	Topic       string // topic name, or topic ID if name is not loaded
+	Board       string // Canny board ID
+	VoteCount   int    // number of votes reported by the source
+	Upvotes     int
+	Downvotes   int
+}
+
+//CommentData is available to the comment template, its authors are set as authors of PostData
+type CommentData struct {
+	Comment     *feedback.Comment
+	Post        PostData // post of the comment
+	Body        string   // body as plain text
+	Date        string   // created date as YYYY-MM-DD, empty if unknown
+	Author      *feedback.User
+	AuthorName  string // author name, or "an unknown user" if the author is unknown
+	DefaultUser bool   // the comment is created by the default user, e.g. the author is unknown or has no email
+}
+
+//ParseTemplates parses templates of post title, post details and comment body. A template starting with @ is read
+//from the file, e.g. @footer.tmpl. Empty template means the default one.
+//Templates are executed with sample data, so unknown fields are reported before the migration
+func ParseTemplates(title, details, comment string) (Templates, error) {
+	var templates Templates
+	var err error
+	unknown := &feedback.User{Name: unknownAuthorName}
+	sample := PostData{Post: &feedback.Post{Author: unknown}, Author: unknown, AuthorName: unknownAuthorName}
+	if templates.Title, err = parseTemplate("title", title, sample); err != nil {
+		return templates, err
+	}
+	if templates.Details, err = parseTemplate("details", details, sample); err != nil {
+		return templates, err
+	}
+	commentSample := CommentData{Comment: &feedback.Comment{Author: unknown}, Post: sample, Author: unknown, AuthorName: unknownAuthorName}
+	if templates.Comment, err = parseTemplate("comment", comment, commentSample); err != nil {
+		return templates, err
+	}
+	return templates, nil
+}
+
+func parseTemplate(name, text string, sample interface{}) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(text, "@") {
+		data, err := ioutil.ReadFile(strings.TrimPrefix(text, "@"))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s template:%w", name, err)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template:%w", name, err)
+	}
+	if err := tmpl.Execute(ioutil.Discard, sample); err != nil {
+		return nil, fmt.Errorf("invalid %s template:%w", name, err)
+	}
+	return tmpl, nil
+}
+
+//postData returns data of the post for templates. DefaultUser is set when the author is resolved
+func (s *Migration) postData(post *feedback.Post, cBoard string) PostData {
+	down := downvotes(post)
+	topic := post.TopicName
+	if topic == "" {
+		topic = post.Topic
+	}
+	tPost := s.templatePost(post)
+	return PostData{
+		Post:       tPost,
+		Title:      strings.TrimSpace(sanitizeString(post.Title)),
+		Details:    strings.TrimSpace(sanitizeString(post.Details)),
+		URL:        post.URL,
+		Date:       formatDate(post.CreatedAt),
+		Author:     tPost.Author,
+		AuthorName: authorName(tPost.Author),
+		Topic:      topic,
+		Board:      cBoard,
+		VoteCount:  post.VoteCount,
+		Upvotes:    len(post.Votes) - down,
+		Downvotes:  down,
+	}
+}
+
+//commentData returns data of the comment for templates. DefaultUser is set when the author is resolved
+func (s *Migration) commentData(post PostData, comment *feedback.Comment) CommentData {
+	tComment := *comment
+	tComment.Author = s.templateUser(comment.Author)
+	return CommentData{
+		Comment:    &tComment,
+		Post:       post,
+		Body:       strings.TrimSpace(sanitizeString(comment.Body)),
+		Date:       formatDate(comment.CreatedAt),
+		Author:     tComment.Author,
+		AuthorName: authorName(tComment.Author),
+	}
+}
+
+//templatePost returns a copy of the post with users as they are available to templates
+func (s *Migration) templatePost(post *feedback.Post) *feedback.Post {
+	tPost := *post
+	tPost.Author = s.templateUser(post.Author)
+	tPost.Comments = make([]*feedback.Comment, len(post.Comments))
+	for i, comment := range post.Comments {
+		tComment := *comment
+		tComment.Author = s.templateUser(comment.Author)
+		tPost.Comments[i] = &tComment
+	}
+	tPost.Votes = make([]*feedback.Vote, len(post.Votes))
+	for i, vote := range post.Votes {
+		tVote := *vote
+		tVote.User = s.templateUser(vote.User)
+		tPost.Votes[i] = &tVote
+	}
+	return &tPost
+}
+
+//templateUser returns the user as it is available to templates: anonymized if users are anonymized,
+//or an unknown user with empty ID if user is nil
+func (s *Migration) templateUser(user *feedback.User) *feedback.User {
+	if user == nil {
+		return &feedback.User{Name: unknownAuthorName}
+	}
+	if s.UserRules.Anonymize {
+		return anonymize(user)
+	}
+	return user
+}
+
+//execute executes the template, or the default one if tmpl is nil
+func execute(tmpl, defaultTmpl *template.Template, data interface{}) (string, error) {
+	if tmpl == nil {
+		tmpl = defaultTmpl
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("cannot execute %s template:%w", tmpl.Name(), err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func formatDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+	return date.Format("2006-01-02")
+}
+
+func authorName(user *feedback.User) string {
+	if user == nil || user.Name == "" {
+		return unknownAuthorName
+	}
+	return user.Name
+}
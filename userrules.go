@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/destination"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
+	"strconv"
+	"strings"
+)
+
+//NoEmailPolicy defines how users without email are migrated
+type NoEmailPolicy string
+
+const (
+	//NoEmailCreate finds or creates the user in Canny by external ID and name, as any other user
+	NoEmailCreate NoEmailPolicy = "create"
+	//NoEmailDefault maps the user to the default user
+	NoEmailDefault NoEmailPolicy = "default"
+	//NoEmailSynthetic creates the user in Canny with a synthetic user ID built from the source user ID
+	NoEmailSynthetic NoEmailPolicy = "synthetic"
+	//NoEmailSkip skips posts, comments and votes of the user
+	NoEmailSkip NoEmailPolicy = "skip"
+)
+
+//errUserSkipped is returned when a post, comment or vote is skipped because of its user
+var errUserSkipped = errors.New("user is skipped")
+
+//UserRules defines how source users are mapped to Canny users, in addition to UserMapping
+type UserRules struct {
+	//EmailMapping maps users by email (ann@example.com) or email domain (@example.com) to a Canny user ID,
+	//e.g. to map all staff to a Canny admin. Exact email has priority over domain
+	EmailMapping map[string]string
+	//MergeByEmail maps source users sharing an email to the same Canny user
+	MergeByEmail bool
+	//NoEmail is a policy for users without email, NoEmailCreate if empty
+	NoEmail NoEmailPolicy
+	//Anonymize replaces names and emails of created Canny users with values derived from a hash of the source user ID.
+	//Every user is still created separately, so vote counts are kept
+	Anonymize bool
+	//MapAgents maps source agents and admins to Canny admins with the same email
+	MapAgents bool
+	//AgentFallback is a Canny user ID of an admin for agents and admins which are not found by email.
+	//Agents are mapped to it even if MapAgents is false
+	AgentFallback string
+	//CustomFields maps source user fields (role, locale, time_zone, organization_id or a custom user field)
+	//to names of Canny user custom fields
+	CustomFields map[string]string
+	//Companies attaches organizations of users as Canny companies
+	Companies bool
+	//MonthlySpendField is a name of an organization field with monthly spend of the company. Optional
+	MonthlySpendField string
+}
+
+//ParseNoEmailPolicy validates the policy name
+func ParseNoEmailPolicy(policy string) (NoEmailPolicy, error) {
+	switch p := NoEmailPolicy(policy); p {
+	case NoEmailCreate, NoEmailDefault, NoEmailSynthetic, NoEmailSkip:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid no-email policy %s, expected create, default, synthetic or skip", policy)
+}
+
+//mappedUser returns a Canny user ID the email is mapped to by email or domain, or empty string
+func (r *UserRules) mappedUser(email string) string {
+	if email == "" || len(r.EmailMapping) == 0 {
+		return ""
+	}
+	email = strings.ToLower(email)
+	if id := r.EmailMapping[email]; id != "" {
+		return id
+	}
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		return r.EmailMapping[email[at:]]
+	}
+	return ""
+}
+
+//mapsAgents reports whether agents and admins are mapped to Canny admins
+func (r *UserRules) mapsAgents() bool {
+	return r.MapAgents || r.AgentFallback != ""
+}
+
+//customFields returns values of the user fields for Canny, or nil if none of fields is set
+func (r *UserRules) customFields(user *feedback.User) map[string]interface{} {
+	var fields map[string]interface{}
+	for field, cannyField := range r.CustomFields {
+		value := user.Field(field)
+		if value == nil {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]interface{})
+		}
+		fields[cannyField] = value
+	}
+	return fields
+}
+
+//companies returns the user organization as Canny companies, or nil
+func (r *UserRules) companies(user *feedback.User) []destination.Company {
+	organization := user.Organization
+	if !r.Companies || organization == nil {
+		return nil
+	}
+	company := destination.Company{
+		ID:      organization.ID,
+		Name:    organization.Name,
+		Created: organization.CreatedAt,
+	}
+	if r.MonthlySpendField != "" {
+		switch spend := organization.Fields[r.MonthlySpendField].(type) {
+		case float64:
+			company.MonthlySpend = spend
+		case string:
+			company.MonthlySpend, _ = strconv.ParseFloat(strings.TrimSpace(spend), 64)
+		}
+	}
+	return []destination.Company{company}
+}
+
+//syntheticUserID returns a Canny user ID for a source user without email or an anonymized user
+func syntheticUserID(user *feedback.User) string {
+	return "migrated-" + user.ID
+}
+
+//anonymize returns a copy of the user with name and email replaced
+func anonymize(user *feedback.User) *feedback.User {
+	hash := sha256.Sum256([]byte(user.ID))
+	short := hex.EncodeToString(hash[:])[:10]
+	return &feedback.User{
+		ID:         user.ID,
+		Name:       "User " + short,
+		ExternalID: "anon-" + short,
+		CreatedAt:  user.CreatedAt,
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
+	flag "github.com/spf13/pflag"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var userColumns = []string{"source_id", "name", "email", "external_id", "created_at", "canny_id"}
+
+//exportUsers writes users saved in state as CSV
+func exportUsers(state *State, w io.Writer) error {
+	ids := make([]string, 0, len(state.Users))
+	for id := range state.Users {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	out := csv.NewWriter(w)
+	if err := out.Write(userColumns); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		user := state.Users[id]
+		var created string
+		if !user.CreatedAt.IsZero() {
+			created = user.CreatedAt.Format(time.RFC3339)
+		}
+		if err := out.Write([]string{id, user.Name, user.Email, user.ExternalID, created, user.CannyID}); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+//importUsers reads users CSV written by exportUsers and updates users in state. It returns number of imported users
+func importUsers(state *State, r io.Reader) (int, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return 0, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	if _, ok := columns["source_id"]; !ok {
+		return 0, fmt.Errorf("source_id column is required")
+	}
+	for i, cells := range rows[1:] {
+		row := func(name string) (string, bool) {
+			if col, ok := columns[name]; ok && col < len(cells) {
+				return strings.TrimSpace(cells[col]), true
+			}
+			return "", false
+		}
+		id, _ := row("source_id")
+		if id == "" {
+			return 0, fmt.Errorf("row %d: source_id is empty", i+2)
+		}
+		//CSV columns are merged into the saved user, so fields which are not exported (role, avatar, organization,
+		//custom fields) are kept
+		userState := state.Users[id]
+		if userState == nil {
+			userState = &UserState{User: feedback.User{ID: id}}
+		}
+		if name, ok := row("name"); ok {
+			userState.Name = name
+		}
+		if email, ok := row("email"); ok {
+			userState.Email = email
+		}
+		if externalID, ok := row("external_id"); ok {
+			userState.ExternalID = externalID
+		}
+		if created, ok := row("created_at"); ok {
+			userState.CreatedAt = time.Time{}
+			if created != "" {
+				userState.CreatedAt, err = time.Parse(time.RFC3339, created)
+				if err != nil {
+					return 0, fmt.Errorf("row %d: invalid created_at: %w", i+2, err)
+				}
+			}
+		}
+		if cannyID, ok := row("canny_id"); ok {
+			userState.CannyID = cannyID
+		}
+		state.Users[id] = userState
+	}
+	return len(rows) - 1, nil
+}
+
+func runUsers(args []string) {
+	fs := flag.NewFlagSet("users", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr,
+			`Usage: zendesk-to-canny users export [--state file] [--out users.csv]
+       zendesk-to-canny users import [--state file] users.csv
+Exports users mapping saved in the state file to CSV for a review, or imports edited CSV back.
+CSV columns: source_id, name, email, external_id, created_at, canny_id.
+Users with canny_id are mapped to that Canny user, users with empty canny_id are found or created in Canny by email or external_id.
+Imported columns are merged into saved users, missing columns and fields which are not exported are kept.
+Options:
+  --state file                 Optional. State file. Default ./state.json
+  --out file                   Optional. CSV file to export to. Default is stdout
+  --help                       Print usage
+`)
+	}
+	helpPtr := fs.Bool("help", false, "")
+	statePtr := fs.String("state", "./state.json", "")
+	outPtr := fs.String("out", "", "")
+	_ = fs.Parse(args)
+	if *helpPtr {
+		fs.Usage()
+		os.Exit(0)
+	}
+	if fs.NArg() == 0 {
+		exitWithUsage(fs.Usage, "export or import command is required")
+	}
+	state, err := loadState(*statePtr)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "cannot load State file:%v\n", err)
+		os.Exit(1)
+	}
+	switch fs.Arg(0) {
+	case "export":
+		w := os.Stdout
+		if *outPtr != "" {
+			w, err = os.Create(*outPtr)
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		err = exportUsers(state, w)
+		if w != os.Stdout {
+			// the file is closed explicitly, so a failed flush is reported
+			if closeErr := w.Close(); err == nil && closeErr != nil {
+				err = fmt.Errorf("cannot write %s:%w", *outPtr, closeErr)
+			}
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "import":
+		if fs.NArg() != 2 {
+			exitWithUsage(fs.Usage, "CSV file to import is required")
+		}
+		f, err := os.Open(fs.Arg(1))
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		n, err := importUsers(state, f)
+		_ = f.Close()
+		if err == nil {
+			err = state.save(*statePtr)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Imported %d users to %s\n", n, *statePtr)
+	default:
+		exitWithUsage(fs.Usage, "unknown users command %s", fs.Arg(0))
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadStateVersion1(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state.json")
+	if err := ioutil.WriteFile(file, []byte(`{"100-Ideas":{"post_1":"p1"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadState(file)
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	if state.getID("100-Ideas", "post", "1") != "p1" {
+		t.Errorf("topics of version 1 state are not loaded: %+v", state.Topics)
+	}
+}
+
+func TestUsersExportImport(t *testing.T) {
+	state := newState()
+	if _, err := importUsers(state, strings.NewReader("source_id,name,email,canny_id\n11,Ann,ann@example.com,\n12,Bob,bob@example.com,c12\n")); err != nil {
+		t.Fatalf("importUsers: %v", err)
+	}
+	if state.Users["12"] == nil || state.Users["12"].CannyID != "c12" || state.Users["11"].Email != "ann@example.com" {
+		t.Errorf("unexpected users %+v", state.Users)
+	}
+	var buf bytes.Buffer
+	if err := exportUsers(state, &buf); err != nil {
+		t.Fatalf("exportUsers: %v", err)
+	}
+	want := "source_id,name,email,external_id,created_at,canny_id\n11,Ann,ann@example.com,,,\n12,Bob,bob@example.com,,,c12\n"
+	if buf.String() != want {
+		t.Errorf("exported:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestUsersImportKeepsFields(t *testing.T) {
+	state := newState()
+	state.Users["11"] = &UserState{
+		User: feedback.User{ID: "11", Name: "Ann", Email: "ann@example.com", Role: feedback.RoleAgent,
+			AvatarURL: "https://example.com/ann.png", OrganizationID: "5", Locale: "en-US",
+			Fields:       map[string]interface{}{"plan": "pro"},
+			Organization: &feedback.Organization{ID: "5", Name: "Acme"}},
+		CannyID: "c11",
+	}
+	var buf bytes.Buffer
+	if err := exportUsers(state, &buf); err != nil {
+		t.Fatalf("exportUsers: %v", err)
+	}
+	//the reviewer maps Ann to another Canny user
+	edited := strings.Replace(buf.String(), ",c11", ",c99", 1)
+	if _, err := importUsers(state, strings.NewReader(edited)); err != nil {
+		t.Fatalf("importUsers: %v", err)
+	}
+	user := state.Users["11"]
+	if user.CannyID != "c99" || user.Name != "Ann" {
+		t.Errorf("CSV columns are not imported: %+v", user)
+	}
+	if user.Role != feedback.RoleAgent || user.AvatarURL == "" || user.Locale != "en-US" ||
+		user.Fields["plan"] != "pro" || user.Organization == nil || user.Organization.Name != "Acme" {
+		t.Errorf("fields which are not exported must be kept: %+v", user)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/destination"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
+	"strings"
+)
+
+//DownvotePolicy defines how downvotes are migrated. Canny supports upvotes only
+type DownvotePolicy string
+
+const (
+	//DownvoteSkip doesn't migrate downvotes
+	DownvoteSkip DownvotePolicy = "skip"
+	//DownvoteComment records every downvote as an internal comment of the voter
+	DownvoteComment DownvotePolicy = "comment"
+	//DownvoteField counts downvotes of a post in a post custom field
+	DownvoteField DownvotePolicy = "field"
+)
+
+//defaultDownvotesField is a name of the post custom field for DownvoteField policy
+const defaultDownvotesField = "downvotes"
+
+//ParseDownvotePolicy validates the policy name
+func ParseDownvotePolicy(policy string) (DownvotePolicy, error) {
+	switch p := DownvotePolicy(policy); p {
+	case DownvoteSkip, DownvoteComment, DownvoteField:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid downvotes policy %s, expected skip, comment or field", policy)
+}
+
+//downvotes returns number of downvotes of the post
+func downvotes(post *feedback.Post) int {
+	var n int
+	for _, vote := range post.Votes {
+		if vote.IsDown() {
+			n++
+		}
+	}
+	return n
+}
+
+//createDownvoteComment records the downvote as an internal comment of the voter
+func (s *Migration) createDownvoteComment(ctx context.Context, vote *feedback.Vote, postID string) (string, error) {
+	userID, err := s.resolveUser(ctx, vote.User, "vote")
+	if err != nil {
+		return "", err
+	}
+	return s.Destination.CreateComment(ctx, destination.Comment{
+		AuthorID: userID,
+		PostID:   postID,
+		Value:    "Downvoted this post before the migration",
+		Internal: true,
+	})
+}
+
+//reconcileVotes compares vote count and net score reported by the source with loaded votes, and loaded upvotes with
+//upvotes migrated to Canny. Downvotes are never migrated as Canny votes, whatever the downvote policy is.
+//It returns a description of differences, or empty string. Posts without reported counts are not checked
+func (s *Migration) reconcileVotes(topic string, post *feedback.Post) string {
+	if post.VoteCount == 0 && post.VoteSum == 0 {
+		return ""
+	}
+	var up, down, migrated int
+	for _, vote := range post.Votes {
+		if vote.IsDown() {
+			down++
+			continue
+		}
+		up++
+		if s.getIDFromState(topic, "vote", vote.ID) != "" {
+			migrated++
+		}
+	}
+	var diffs []string
+	if up+down != post.VoteCount {
+		diffs = append(diffs, fmt.Sprintf("%d votes reported, %d loaded", post.VoteCount, up+down))
+	}
+	if up-down != post.VoteSum {
+		diffs = append(diffs, fmt.Sprintf("net score %d reported, %d loaded (%d up, %d down)", post.VoteSum, up-down, up, down))
+	}
+	if migrated != up {
+		diffs = append(diffs, fmt.Sprintf("%d upvotes loaded, %d migrated", up, migrated))
+	}
+	return strings.Join(diffs, "; ")
+}
@@ -0,0 +1,42 @@
+package zendesk
+
+import (
+	"net/http"
+)
+
+//Authenticator adds credentials to every request sent to Zendesk API
+type Authenticator interface {
+	Authenticate(req *http.Request)
+}
+
+//PasswordAuth authenticates with a Zendesk user email and password using basic auth
+type PasswordAuth struct {
+	Username string
+	Password string
+}
+
+//Authenticate implements Authenticator
+func (a PasswordAuth) Authenticate(req *http.Request) {
+	req.SetBasicAuth(a.Username, a.Password)
+}
+
+//TokenAuth authenticates with a Zendesk API token, sent as basic auth with '{email}/token:{token}'
+type TokenAuth struct {
+	Email string
+	Token string
+}
+
+//Authenticate implements Authenticator
+func (a TokenAuth) Authenticate(req *http.Request) {
+	req.SetBasicAuth(a.Email+"/token", a.Token)
+}
+
+//BearerAuth authenticates with a Zendesk OAuth access token
+type BearerAuth struct {
+	Token string
+}
+
+//Authenticate implements Authenticator
+func (a BearerAuth) Authenticate(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+}
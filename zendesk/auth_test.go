@@ -0,0 +1,43 @@
+package zendesk
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientAuthorizationHeader(t *testing.T) {
+	basic := func(user, pass string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	}
+	tests := []struct {
+		name string
+		auth Authenticator
+		want string
+	}{
+		{"password", PasswordAuth{Username: "agent@example.com", Password: "secret"}, basic("agent@example.com", "secret")},
+		{"api token", TokenAuth{Email: "agent@example.com", Token: "abc123"}, basic("agent@example.com/token", "abc123")},
+		{"oauth", BearerAuth{Token: "oauth-token"}, "Bearer oauth-token"},
+		{"none", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("Authorization")
+				_, _ = w.Write([]byte(`{"users":[]}`))
+			}))
+			defer srv.Close()
+			cli := &Client{Auth: tt.auth, BaseURL: srv.URL}
+			var resp usersResponse
+			if err := cli.get(context.Background(), srv.URL+"/api/v2/users/show_many.json?ids=1", &resp); err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package zendesk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	//ErrUnauthorized is matched by APIError when credentials are invalid or have no access
+	ErrUnauthorized = errors.New("zendesk: unauthorized")
+	//ErrNotFound is matched by APIError when the requested topic, post or user doesn't exist
+	ErrNotFound = errors.New("zendesk: not found")
+	//ErrRateLimited is matched by APIError when Zendesk API rate limit is exceeded
+	ErrRateLimited = errors.New("zendesk: rate limited")
+)
+
+//APIError describes a non-200 response from Zendesk API.
+//Use errors.Is with ErrUnauthorized, ErrNotFound or ErrRateLimited to check its kind
+type APIError struct {
+	StatusCode int
+	Endpoint   string // request path, e.g. /api/v2/community/posts/1/comments.json
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("zendesk %s: %d - %s", e.Endpoint, e.StatusCode, e.Body)
+}
+
+//Is reports whether the error matches one of sentinel errors
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
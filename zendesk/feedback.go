@@ -0,0 +1,188 @@
+package zendesk
+
+import (
+	"context"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
+	"strconv"
+)
+
+const defaultParallel = 10
+
+//Posts implements feedback.Source. Details of Client.Parallel posts are loaded in parallel
+func (s *Client) Posts(ctx context.Context, topic string, errCB feedback.ErrorCallback) *feedback.Stream {
+	parallel := s.Parallel
+	if parallel <= 0 {
+		parallel = defaultParallel
+	}
+	var topicName string
+	if s.LoadTopicName {
+		details, err := s.GetTopic(ctx, topic)
+		if err != nil && errCB != nil {
+			errCB(err)
+		}
+		if details != nil {
+			topicName = details.Name
+		}
+	}
+	return FeedbackStream(ctx, topic, topicName, s.StreamPosts(ctx, topic, parallel, PostLoadingErrorCallback(errCB)))
+}
+
+//Post implements feedback.PostLoader
+func (s *Client) Post(ctx context.Context, topic, id string, parts feedback.PostParts) (*feedback.Post, error) {
+	postID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid post ID %s", id)
+	}
+	post, err := s.GetPostParts(ctx, postID, parts.Comments, parts.Votes)
+	if err != nil {
+		return nil, err
+	}
+	fPost := post.Feedback(topic)
+	if s.LoadTopicName {
+		details, err := s.GetTopic(ctx, topic)
+		if err != nil {
+			return nil, err
+		}
+		fPost.TopicName = details.Name
+	}
+	return fPost, nil
+}
+
+//FeedbackStream converts a stream of Zendesk posts of a topic to a stream of feedback posts. topicName is optional
+func FeedbackStream(ctx context.Context, topic, topicName string, stream *PostStream) *feedback.Stream {
+	return feedback.NewStream(func(posts chan<- *feedback.Post) error {
+		for post := range stream.Posts {
+			fPost := post.Feedback(topic)
+			fPost.TopicName = topicName
+			select {
+			case posts <- fPost:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return stream.Err()
+	})
+}
+
+//Feedback converts the post with its comments, votes and users to feedback types
+func (p *Post) Feedback(topic string) *feedback.Post {
+	post := &feedback.Post{
+		ID:                 formatID(p.ID),
+		Topic:              topic,
+		Title:              p.Title,
+		Details:            p.Details,
+		URL:                p.HTMLURL,
+		CreatedAt:          p.CreatedAt,
+		Featured:           p.Featured,
+		Pinned:             p.Pinned,
+		VoteCount:          p.VoteCount,
+		VoteSum:            p.VoteSum,
+		CommentsIncomplete: p.CommentsIncomplete,
+		VotesIncomplete:    p.VotesIncomplete,
+		Author:             p.Author.Feedback(),
+		Comments:           make([]*feedback.Comment, 0, len(p.Comments)),
+		Votes:              make([]*feedback.Vote, 0, len(p.UserVotes)),
+	}
+	for _, tag := range p.ContentTags {
+		post.Tags = append(post.Tags, tag.Name)
+	}
+	for _, c := range p.Comments {
+		post.Comments = append(post.Comments, &feedback.Comment{
+			ID:        formatID(c.ID),
+			Body:      c.Body,
+			CreatedAt: c.CreatedAt,
+			Author:    c.Author.Feedback(),
+		})
+	}
+	for _, v := range p.UserVotes {
+		post.Votes = append(post.Votes, &feedback.Vote{
+			ID:    formatID(v.ID),
+			Value: v.Value,
+			User:  v.User.Feedback(),
+		})
+	}
+	return post
+}
+
+//Feedback converts the user to feedback user. It returns nil for nil user
+func (u *User) Feedback() *feedback.User {
+	if u == nil {
+		return nil
+	}
+	user := &feedback.User{
+		ID:         formatID(u.ID),
+		Name:       u.Name,
+		Email:      u.Email,
+		ExternalID: u.ExternalID,
+		CreatedAt:  u.CreatedAt,
+		Role:       u.Role,
+		Locale:     u.Locale,
+		TimeZone:   u.TimeZone,
+		Fields:     u.UserFields,
+	}
+	if u.Photo != nil {
+		user.AvatarURL = u.Photo.ContentURL
+	}
+	if u.OrganizationID != 0 {
+		user.OrganizationID = formatID(u.OrganizationID)
+	}
+	if u.Organization != nil {
+		user.Organization = &feedback.Organization{
+			ID:        formatID(u.Organization.ID),
+			Name:      u.Organization.Name,
+			CreatedAt: u.Organization.CreatedAt,
+			Fields:    u.Organization.OrganizationFields,
+		}
+	}
+	return user
+}
+
+func formatID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+//AddUsers implements feedback.UserCache. Users with non-numeric IDs are ignored
+func (s *Client) AddUsers(users []*feedback.User) {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	if s.Users == nil {
+		s.Users = make(map[int64]*User)
+	}
+	for _, user := range users {
+		id, err := strconv.ParseInt(user.ID, 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := s.Users[id]; ok {
+			continue
+		}
+		zUser := &User{
+			ID:         id,
+			CreatedAt:  user.CreatedAt,
+			Name:       user.Name,
+			Email:      user.Email,
+			ExternalID: user.ExternalID,
+			Role:       user.Role,
+			Locale:     user.Locale,
+			TimeZone:   user.TimeZone,
+			UserFields: user.Fields,
+		}
+		if user.AvatarURL != "" {
+			zUser.Photo = &Attachment{ContentURL: user.AvatarURL}
+		}
+		zUser.OrganizationID, _ = strconv.ParseInt(user.OrganizationID, 10, 64)
+		if organization := user.Organization; organization != nil {
+			orgID, err := strconv.ParseInt(organization.ID, 10, 64)
+			if err == nil {
+				zUser.Organization = &Organization{
+					ID:                 orgID,
+					Name:               organization.Name,
+					CreatedAt:          organization.CreatedAt,
+					OrganizationFields: organization.Fields,
+				}
+			}
+		}
+		s.Users[id] = zUser
+	}
+}
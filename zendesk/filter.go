@@ -0,0 +1,78 @@
+package zendesk
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//Filter selects posts to load. Empty fields don't filter posts
+type Filter struct {
+	Since           time.Time // posts created (updated if ByUpdated is set) before Since are skipped
+	Until           time.Time // posts created (updated if ByUpdated is set) at or after Until are skipped
+	ByUpdated       bool
+	MinVotes        int
+	MinComments     int
+	Statuses        []string // only posts with these statuses are loaded
+	ExcludeStatuses []string
+	ExcludeClosed   bool
+	TitleMatch      *regexp.Regexp // only posts with matching titles are loaded
+	TitleExclude    *regexp.Regexp
+	IDs             map[int64]bool // only these posts are loaded
+	ExcludeIDs      map[int64]bool
+}
+
+//Skip returns the reason to skip the post, or empty string if the post passes the filter. Nil filter passes all posts
+func (f *Filter) Skip(post *Post) string {
+	if f == nil {
+		return ""
+	}
+	if f.IDs != nil && !f.IDs[post.ID] {
+		return "not in the list of post IDs"
+	}
+	if f.ExcludeIDs[post.ID] {
+		return "in the list of excluded post IDs"
+	}
+	date, dateName := post.CreatedAt, "created"
+	if f.ByUpdated {
+		date, dateName = post.UpdatedAt, "updated"
+	}
+	if !f.Since.IsZero() && date.Before(f.Since) {
+		return fmt.Sprintf("%s at %s, before %s", dateName, date.Format(time.RFC3339), f.Since.Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() && !date.Before(f.Until) {
+		return fmt.Sprintf("%s at %s, not before %s", dateName, date.Format(time.RFC3339), f.Until.Format(time.RFC3339))
+	}
+	if post.VoteCount < f.MinVotes {
+		return fmt.Sprintf("%d votes, less than %d", post.VoteCount, f.MinVotes)
+	}
+	if post.CommentCount < f.MinComments {
+		return fmt.Sprintf("%d comments, less than %d", post.CommentCount, f.MinComments)
+	}
+	if len(f.Statuses) > 0 && !containsFold(f.Statuses, post.Status) {
+		return fmt.Sprintf("status '%s' is not included", post.Status)
+	}
+	if containsFold(f.ExcludeStatuses, post.Status) {
+		return fmt.Sprintf("status '%s' is excluded", post.Status)
+	}
+	if f.ExcludeClosed && post.Closed {
+		return "closed"
+	}
+	if f.TitleMatch != nil && !f.TitleMatch.MatchString(post.Title) {
+		return fmt.Sprintf("title doesn't match '%s'", f.TitleMatch)
+	}
+	if f.TitleExclude != nil && f.TitleExclude.MatchString(post.Title) {
+		return fmt.Sprintf("title matches excluded '%s'", f.TitleExclude)
+	}
+	return ""
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
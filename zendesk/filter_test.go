@@ -0,0 +1,43 @@
+package zendesk_test
+
+import (
+	"github.com/Pleexy/zendesk-to-canny/zendesk"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFilterSkip(t *testing.T) {
+	created := time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	post := &zendesk.Post{ID: 1, Title: "Dark mode", VoteCount: 2, CommentCount: 1, Status: "planned",
+		CreatedAt: created, UpdatedAt: updated}
+	tests := []struct {
+		name   string
+		filter *zendesk.Filter
+		skip   bool
+	}{
+		{name: "nil", filter: nil},
+		{name: "since", filter: &zendesk.Filter{Since: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}, skip: true},
+		{name: "since updated", filter: &zendesk.Filter{Since: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), ByUpdated: true}},
+		{name: "until", filter: &zendesk.Filter{Until: created}, skip: true},
+		{name: "min votes", filter: &zendesk.Filter{MinVotes: 3}, skip: true},
+		{name: "min comments", filter: &zendesk.Filter{MinComments: 1}},
+		{name: "status", filter: &zendesk.Filter{Statuses: []string{"Planned", "completed"}}},
+		{name: "status not included", filter: &zendesk.Filter{Statuses: []string{"completed"}}, skip: true},
+		{name: "excluded status", filter: &zendesk.Filter{ExcludeStatuses: []string{"planned"}}, skip: true},
+		{name: "closed", filter: &zendesk.Filter{ExcludeClosed: true}},
+		{name: "title match", filter: &zendesk.Filter{TitleMatch: regexp.MustCompile("(?i)dark")}},
+		{name: "title exclude", filter: &zendesk.Filter{TitleExclude: regexp.MustCompile("mode$")}, skip: true},
+		{name: "ids", filter: &zendesk.Filter{IDs: map[int64]bool{2: true}}, skip: true},
+		{name: "excluded ids", filter: &zendesk.Filter{ExcludeIDs: map[int64]bool{1: true}}, skip: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason := test.filter.Skip(post)
+			if (reason != "") != test.skip {
+				t.Errorf("Skip returned '%s', want skip %v", reason, test.skip)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package zendesk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+//cursorPageSize is a number of items requested per page with cursor pagination, maximum allowed by Zendesk
+const cursorPageSize = 100
+
+//maxOffsetPages is a number of pages Zendesk returns with offset pagination, next_page of the last one is empty
+//even if more items are present
+const maxOffsetPages = 100
+
+//ResponseFooter describe fields returned with every list request. Meta and Links are returned with cursor pagination,
+//NextPage with offset pagination
+type ResponseFooter struct {
+	NextPage string       `json:"next_page"`
+	Count    int          `json:"count"`
+	Meta     *CursorMeta  `json:"meta"`
+	Links    *CursorLinks `json:"links"`
+}
+
+//CursorMeta describes meta of a page returned with cursor pagination
+type CursorMeta struct {
+	HasMore     bool   `json:"has_more"`
+	AfterCursor string `json:"after_cursor"`
+}
+
+//CursorLinks describes links of a page returned with cursor pagination
+type CursorLinks struct {
+	Next string `json:"next"`
+}
+
+//next returns URL of the next page, or empty string for the last page
+func (f *ResponseFooter) next() string {
+	if f.Meta != nil {
+		if f.Meta.HasMore && f.Links != nil {
+			return f.Links.Next
+		}
+		return ""
+	}
+	return f.NextPage
+}
+
+func (f *ResponseFooter) footer() *ResponseFooter {
+	return f
+}
+
+//page is a response of a list request
+type page interface {
+	footer() *ResponseFooter
+	//missing reports whether items are missing in the response
+	missing() bool
+	//size returns a number of items in the response
+	size() int
+}
+
+func (r *postsResponse) missing() bool    { return r.Posts == nil }
+func (r *commentsResponse) missing() bool { return r.Comments == nil }
+func (r *votesResponse) missing() bool    { return r.Votes == nil }
+
+func (r *postsResponse) size() int    { return len(r.Posts) }
+func (r *commentsResponse) size() int { return len(r.Comments) }
+func (r *votesResponse) size() int    { return len(r.Votes) }
+
+//paginate requests pages of a list starting from url and calls onPage for every page. Cursor pagination is requested,
+//offset pagination (next_page) is followed if the endpoint doesn't support cursors. what describes the list for errors.
+//An error is returned after onPage if offset pagination stops at the Zendesk pages limit before all items are loaded
+func (s *Client) paginate(ctx context.Context, what, url string, newPage func() page, onPage func(p page) error) error {
+	url = withCursor(url)
+	var loaded int
+	for n := 0; ; n++ {
+		p := newPage()
+		if err := s.get(ctx, url, p); err != nil {
+			return fmt.Errorf("error while getting page %d of %s: %w", n, what, err)
+		}
+		next := p.footer().next()
+		if p.missing() && next != "" {
+			return fmt.Errorf("%s are not found on page %d while next page is present", what, n)
+		}
+		if err := onPage(p); err != nil {
+			return err
+		}
+		loaded += p.size()
+		if next == "" {
+			footer := p.footer()
+			if footer.Meta == nil && n+1 >= maxOffsetPages && footer.Count > loaded {
+				return fmt.Errorf("%s are truncated at the Zendesk limit of %d pages with offset pagination, %d of %d are loaded",
+					what, maxOffsetPages, loaded, footer.Count)
+			}
+			return nil
+		}
+		if next == url {
+			return fmt.Errorf("next page of %s is the same as page %d", what, n)
+		}
+		url = next
+	}
+}
+
+//withCursor adds cursor pagination parameters to the first page url
+func withCursor(url string) string {
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%spage[size]=%d", url, sep, cursorPageSize)
+}
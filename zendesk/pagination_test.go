@@ -0,0 +1,70 @@
+package zendesk_test
+
+import (
+	"context"
+	"github.com/Pleexy/zendesk-to-canny/zendesk"
+	"github.com/Pleexy/zendesk-to-canny/zendesk/zendesktest"
+	"strings"
+	"testing"
+)
+
+func TestStreamPostsPagination(t *testing.T) {
+	const topic = "100-Ideas"
+	const total = 250
+	tests := []struct {
+		name       string
+		offsetOnly bool
+		maxPages   int
+		want       int
+		wantErr    bool // posts are truncated at the offset pages limit
+	}{
+		{name: "cursor", want: total},
+		{name: "offset fallback", offsetOnly: true, maxPages: 1000, want: total},
+		{name: "offset limit", offsetOnly: true, maxPages: 100, want: 100, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			z := zendesktest.NewServer()
+			defer z.Close()
+			z.PageSize = 1
+			z.OffsetOnly = test.offsetOnly
+			if test.maxPages > 0 {
+				z.MaxOffsetPages = test.maxPages
+			}
+			for i := 1; i <= total; i++ {
+				z.AddPost(topic, &zendesk.Post{ID: int64(i), Title: "Idea"}, nil, nil)
+			}
+			client := &zendesk.Client{BaseURL: z.URL}
+			stream := client.StreamPosts(context.Background(), topic, 2, func(err error) {
+				t.Errorf("unexpected error: %v", err)
+			})
+			seen := make(map[int64]bool)
+			for post := range stream.Posts {
+				if seen[post.ID] {
+					t.Errorf("post %d is loaded twice", post.ID)
+				}
+				seen[post.ID] = true
+			}
+			err := stream.Err()
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "100 of 250 are loaded") {
+					t.Errorf("expected truncation error, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("StreamPosts: %v", err)
+			}
+			if len(seen) != test.want {
+				t.Errorf("loaded %d posts, want %d", len(seen), test.want)
+			}
+			var cursorRequests int
+			for _, req := range z.Requests() {
+				if strings.Contains(req, "page[after]") || strings.Contains(req, "page%5Bafter%5D") {
+					cursorRequests++
+				}
+			}
+			if !test.offsetOnly && cursorRequests != total-1 {
+				t.Errorf("expected %d cursor requests, got %d", total-1, cursorRequests)
+			}
+		})
+	}
+}
@@ -2,64 +2,153 @@ package zendesk
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/feedback"
+	"github.com/Pleexy/zendesk-to-canny/httpclient"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	defaultRetries      = 3
+	defaultRetryBackoff = time.Second
+	//userBatchSize is a number of users requested by one users/show_many request, maximum allowed by Zendesk
+	userBatchSize = 100
+)
+
 //Client implements client to access Zendesk API
 type Client struct {
-	Username string
-	Password string
+	Auth     Authenticator
 	BaseURL  string
 	Users    map[int64]*User
+	Parallel int // number of posts which details are loaded in parallel by Posts, default 10
+	//LoadOrganizations enables loading of organizations of users, set to User.Organization
+	LoadOrganizations bool
+	Organizations     map[int64]*Organization
+	//LoadContentTags enables loading of content tags of posts, set to Post.ContentTags
+	LoadContentTags bool
+	ContentTags     map[string]*ContentTag
+	//LoadTopicName enables loading of the topic name by Posts, set to feedback.Post.TopicName
+	LoadTopicName bool
+	//Retries is a number of retries of a failed request of post comments or votes, default 3.
+	//Posts are kept marked as incomplete if comments or votes still cannot be loaded
+	Retries int
+	//RetryBackoff is a delay before the first retry, doubled for every next retry. Default 1s
+	RetryBackoff time.Duration
+	//Filter skips posts before their comments and votes are loaded. Skipped posts are reported to the error
+	//callback as *feedback.SkippedError. Optional
+	Filter     *Filter
+	sideloaded map[int64]bool // IDs of side-loaded users which were not used by any post yet
+	statsMu    sync.Mutex
+	stats      Stats
+	usersMu    sync.Mutex
+	HTTPClient *http.Client // optional, httpclient.Default is used if not set. Custom http.RoundTripper can be provided as its Transport
 }
 
 //User describes fields of Zendesk User that are used by migration
 type User struct {
-	ID         int64     `json:"id"`
-	CreatedAt  time.Time `json:"created_at"`
-	Name       string    `json:"name"`
-	Email      string    `json:"email"`
-	ExternalID string    `json:"external_id"`
+	ID             int64                  `json:"id"`
+	CreatedAt      time.Time              `json:"created_at"`
+	Name           string                 `json:"name"`
+	Email          string                 `json:"email"`
+	ExternalID     string                 `json:"external_id"`
+	Role           string                 `json:"role"` // end-user, agent or admin
+	Photo          *Attachment            `json:"photo"`
+	OrganizationID int64                  `json:"organization_id"`
+	Locale         string                 `json:"locale"`
+	TimeZone       string                 `json:"time_zone"`
+	UserFields     map[string]interface{} `json:"user_fields"`
+	Organization   *Organization          `json:"organization,omitempty"` // loaded if Client.LoadOrganizations is set
+}
+
+//Organization describes fields of Zendesk Organization that are used by migration
+type Organization struct {
+	ID                 int64                  `json:"id"`
+	Name               string                 `json:"name"`
+	CreatedAt          time.Time              `json:"created_at"`
+	OrganizationFields map[string]interface{} `json:"organization_fields"`
+}
+
+//Attachment describes fields of Zendesk attachment, e.g. user photo
+type Attachment struct {
+	ID         int64  `json:"id"`
+	FileName   string `json:"file_name"`
+	ContentURL string `json:"content_url"`
+}
+
+//Topic describes fields of Zendesk community Topic
+type Topic struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	HTMLURL     string    `json:"html_url"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 //Post describes fields of Zendesk Post that are used by migration
 type Post struct {
-	ID           int64  `json:"id"`
-	Title        string `json:"title"`
-	Details      string `json:"details"`
-	AuthorID     int64  `json:"author_id"`
-	VoteCount    int    `json:"vote_count"`
-	CommentCount int    `json:"comment_count"`
-	Comments     []*Comment
-	UserVotes    []*Vote
-	Author       *User
+	ID            int64         `json:"id"`
+	Title         string        `json:"title"`
+	Details       string        `json:"details"`
+	AuthorID      int64         `json:"author_id"`
+	VoteCount     int           `json:"vote_count"`
+	VoteSum       int           `json:"vote_sum"`
+	CommentCount  int           `json:"comment_count"`
+	TopicID       int64         `json:"topic_id"`
+	HTMLURL       string        `json:"html_url"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	Featured      bool          `json:"featured"`
+	Pinned        bool          `json:"pinned"`
+	Status        string        `json:"status"` // none, planned, not_planned, answered or completed
+	Closed        bool          `json:"closed"`
+	ContentTagIDs []string      `json:"content_tag_ids"`
+	ContentTags   []*ContentTag `json:"content_tags,omitempty"` // loaded if Client.LoadContentTags is set
+	Comments      []*Comment    `json:"-"`
+	UserVotes     []*Vote       `json:"-"`
+	Author        *User         `json:"-"`
+	//CommentsIncomplete and VotesIncomplete are set if comments or votes cannot be loaded
+	CommentsIncomplete bool `json:"comments_incomplete,omitempty"`
+	VotesIncomplete    bool `json:"votes_incomplete,omitempty"`
+}
+
+//ContentTag describes fields of Zendesk Help Center content tag
+type ContentTag struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 //Comment describes fields of Zendesk Comment that are used by migration
 type Comment struct {
-	ID       int64
-	Body     string
-	AuthorID int64 `json:"author_id"`
-	Author   *User
+	ID        int64     `json:"id"`
+	Body      string    `json:"body"`
+	AuthorID  int64     `json:"author_id"`
+	Official  bool      `json:"official"`
+	HTMLURL   string    `json:"html_url"`
+	CreatedAt time.Time `json:"created_at"`
+	Author    *User     `json:"-"`
 }
 
 //Vote describes fields of Zendesk Vote that are used by migration
 type Vote struct {
-	ID     int64 `json:"id"`
-	UserID int64 `json:"user_id"`
-	User   *User
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Value     int       `json:"value"` // 1 for upvote, -1 for downvote
+	CreatedAt time.Time `json:"created_at"`
+	User      *User     `json:"-"`
 }
 
-//ResponseFooter describe fields returned with every list request
-type ResponseFooter struct {
-	NextPage string `json:"next_page"`
-	Count    int    `json:"count"`
+type topicResponse struct {
+	Topic *Topic
 }
 
 type usersResponse struct {
@@ -67,12 +156,27 @@ type usersResponse struct {
 	ResponseFooter
 }
 
+type contentTagResponse struct {
+	ContentTag *ContentTag `json:"content_tag"`
+}
+
+type organizationsResponse struct {
+	Organizations []*Organization
+	ResponseFooter
+}
+
+type postResponse struct {
+	Post *Post
+}
+
 type postsResponse struct {
 	Posts []Post
+	Users []*User // side-loaded with include=users
 	ResponseFooter
 }
 type commentsResponse struct {
 	Comments []*Comment
+	Users    []*User // side-loaded with include=users
 	ResponseFooter
 }
 type votesResponse struct {
@@ -80,231 +184,681 @@ type votesResponse struct {
 	ResponseFooter
 }
 
+//Stats contains numbers of API requests made by the client
+type Stats struct {
+	Requests        int // API requests made, excluding downloads
+	SideloadedUsers int // users side-loaded with posts and comments, which are not requested by users/show_many
+	SavedRequests   int // users/show_many batches of 100 users avoided by side-loaded users
+}
+
+//Stats returns numbers of API requests made by the client so far
+func (s *Client) Stats() Stats {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	stats := s.stats
+	stats.SavedRequests = batchCount(stats.SideloadedUsers, userBatchSize)
+	return stats
+}
+
 //PostLoadedCallback describe a function that is called for every loaded post.
 type PostLoadedCallback func(post *Post)
 
 //PostLoadingErrorCallback describe a function that is called for error occured during post loading
 type PostLoadingErrorCallback func(err error)
 
-//GetPosts return all posts for specific topic
-func (s *Client) GetPosts(topic string, parallel int, postCB PostLoadedCallback, errCB PostLoadingErrorCallback) ([]*Post, []error, error) {
+//PostStream is a stream of posts of a topic. Every post is sent to Posts as soon as its comments, votes and users are loaded
+type PostStream struct {
+	Posts <-chan *Post
+	done  chan struct{}
+	err   error
+}
+
+//NewPostStream creates a stream of posts produced by load, e.g. from a file. load is called in a separate goroutine,
+//it must send posts to the channel until it is done or ctx is cancelled and return a fatal error, if any
+func NewPostStream(load func(posts chan<- *Post) error) *PostStream {
+	posts := make(chan *Post)
+	stream := &PostStream{
+		Posts: posts,
+		done:  make(chan struct{}),
+	}
+	go func() {
+		stream.err = load(posts)
+		close(posts)
+		close(stream.done)
+	}()
+	return stream
+}
+
+//Err waits until the stream is finished and returns a fatal error that stopped loading of the topic, if any
+func (s *PostStream) Err() error {
+	<-s.done
+	return s.err
+}
+
+//GetTopic returns a topic by its ID
+func (s *Client) GetTopic(ctx context.Context, topic string) (*Topic, error) {
+	var response topicResponse
+	err := s.get(ctx, fmt.Sprintf("%s/api/v2/community/topics/%s.json", s.BaseURL, topic), &response)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting topic %s: %w", topic, err)
+	}
+	if response.Topic == nil {
+		return nil, fmt.Errorf("topic %s is not found in response", topic)
+	}
+	return response.Topic, nil
+}
+
+//GetPosts return all posts for specific topic. Loading stops when ctx is cancelled
+func (s *Client) GetPosts(ctx context.Context, topic string, parallel int, postCB PostLoadedCallback, errCB PostLoadingErrorCallback) ([]*Post, []error, error) {
 	posts := make([]*Post, 0)
 	errs := make([]error, 0)
+	stream := s.StreamPosts(ctx, topic, parallel, func(err error) {
+		errs = append(errs, err)
+		if errCB != nil {
+			errCB(err)
+		}
+	})
+	for post := range stream.Posts {
+		posts = append(posts, post)
+		if postCB != nil {
+			postCB(post)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, nil, err
+	}
+	return posts, errs, nil
+}
+
+//StreamPosts loads posts of a topic in parallel and streams them with comments, votes and users in the listing order.
+//Users of posts loaded together are requested in batches, up to 100 posts are held in memory to collect them. errCB is called for errors that don't stop loading,
+//it is called from one goroutine at a time. Loading stops when ctx is cancelled
+func (s *Client) StreamPosts(ctx context.Context, topic string, parallel int, errCB PostLoadingErrorCallback) *PostStream {
+	s.usersMu.Lock()
 	if s.Users == nil {
 		s.Users = make(map[int64]*User)
 	}
+	s.usersMu.Unlock()
 	loadPostsCh := make(chan *Post)
-	loadedPostsCh, usersCh, errorsCh := s.detailsLoader(loadPostsCh, parallel)
-	var routinesWG sync.WaitGroup
-	routinesWG.Add(3)
-	go func() {
-		for loadedPost := range loadedPostsCh {
-			posts = append(posts, loadedPost)
-			if postCB != nil {
-				postCB(loadedPost)
-			}
-		}
-		routinesWG.Done()
-	}()
+	loadedPostsCh, errorsCh := s.detailsLoader(ctx, loadPostsCh, parallel)
+	stream := &PostStream{
+		Posts: loadedPostsCh,
+		done:  make(chan struct{}),
+	}
+	errorsDone := make(chan struct{})
 	go func() {
 		for loadErr := range errorsCh {
-			errs = append(errs, loadErr)
 			if errCB != nil {
 				errCB(loadErr)
 			}
 		}
-		routinesWG.Done()
+		close(errorsDone)
 	}()
-	usersToLoad := make([]int64, 0)
 	go func() {
-		for userID := range usersCh {
-			if _, ok := s.Users[userID]; !ok {
-				s.Users[userID] = nil // we are loading it
-				usersToLoad = append(usersToLoad, userID)
+		stream.err = s.loadPosts(ctx, topic, loadPostsCh)
+		close(loadPostsCh)
+		<-errorsDone
+		close(stream.done)
+	}()
+	return stream
+}
+
+func (s *Client) loadPosts(ctx context.Context, topic string, loadPostsCh chan<- *Post) error {
+	url := fmt.Sprintf("%s/api/v2/community/topics/%s/posts.json?sort_by=created_at&sort_order=asc&include=users", s.BaseURL, topic)
+	return s.paginate(ctx, "posts", url, func() page { return &postsResponse{} }, func(p page) error {
+		response := p.(*postsResponse)
+		s.addSideloadedUsers(response.Users)
+		for _, post := range response.Posts {
+			postVar := post
+			select {
+			case loadPostsCh <- &postVar:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
 		}
-		routinesWG.Done()
-	}()
-	url := fmt.Sprintf("%s/api/v2/community/topics/%s/posts.json?sort_by=created_at", s.BaseURL, topic)
-	page := 0
-	var fatalError error
-	for true {
-		var response postsResponse
-		err := s.get(url, &response)
-		if err != nil {
-			fatalError = fmt.Errorf("error while getting page %d of posts: %w", page, err)
-			break
+		return nil
+	})
+}
+
+func (s *Client) setUsers(post *Post) {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	post.Author = s.Users[post.AuthorID]
+	for _, comment := range post.Comments {
+		comment.Author = s.Users[comment.AuthorID]
+	}
+	for _, vote := range post.UserVotes {
+		vote.User = s.Users[vote.UserID]
+	}
+	if s.LoadOrganizations {
+		s.forEachUser(post, func(user *User) {
+			// users are shared by posts, set the organization once to not race with readers of sent posts
+			if organization := s.Organizations[user.OrganizationID]; organization != nil && user.Organization == nil {
+				user.Organization = organization
+			}
+		})
+	}
+}
+
+//forEachUser calls f for every loaded user of the post, comments and votes. usersMu must be locked
+func (s *Client) forEachUser(post *Post, f func(user *User)) {
+	users := []*User{post.Author}
+	for _, comment := range post.Comments {
+		users = append(users, comment.Author)
+	}
+	for _, vote := range post.UserVotes {
+		users = append(users, vote.User)
+	}
+	for _, user := range users {
+		if user != nil {
+			f(user)
+		}
+	}
+}
+
+//loadDetails loads requested comments and votes, and users of the post. Comments and votes are retried with backoff,
+//if they still cannot be loaded, the post is marked incomplete and the error is sent to incompleteCB.
+//An error is returned if users cannot be loaded
+func (s *Client) loadDetails(ctx context.Context, post *Post, comments, votes bool, incompleteCB func(err error)) error {
+	s.loadContent(ctx, post, comments, votes, incompleteCB)
+	if err := s.loadPostsUsers(ctx, []*Post{post}); err != nil {
+		return fmt.Errorf("cannot load users for postID=%d: %w", post.ID, err)
+	}
+	return s.loadContentTags(ctx, post)
+}
+
+//loadContent loads requested comments and votes of the post. Comments and votes are retried with backoff,
+//if they still cannot be loaded, the post is marked incomplete and the error is sent to incompleteCB
+func (s *Client) loadContent(ctx context.Context, post *Post, comments, votes bool, incompleteCB func(err error)) {
+	if comments && post.CommentCount > 0 {
+		err := s.retry(ctx, func() error {
+			comments, err := s.getComments(ctx, post.ID)
+			post.Comments = comments
+			return err
+		})
+		if err != nil && ctx.Err() == nil {
+			post.CommentsIncomplete = true
+			incompleteCB(fmt.Errorf("comments of postID=%d are incomplete: %w", post.ID, err))
 		}
-		if response.Posts == nil && response.NextPage != "" {
-			fatalError = fmt.Errorf("posts are not found on page %d while next page is present", page)
-			break
+	}
+	if votes && post.VoteCount > 0 {
+		err := s.retry(ctx, func() error {
+			votes, err := s.getVotes(ctx, post.ID)
+			post.UserVotes = votes
+			return err
+		})
+		if err != nil && ctx.Err() == nil {
+			post.VotesIncomplete = true
+			incompleteCB(fmt.Errorf("votes of postID=%d are incomplete: %w", post.ID, err))
 		}
-		for _, post := range response.Posts {
-			postVar := post
-			loadPostsCh <- &postVar
+	}
+}
+
+//loadPostsUsers loads missing users of posts, and their organizations if LoadOrganizations is set,
+//in batches shared by all posts, and sets them to posts
+func (s *Client) loadPostsUsers(ctx context.Context, posts []*Post) error {
+	if err := s.loadUsers(ctx, s.missingUsers(posts)); err != nil {
+		return err
+	}
+	for _, post := range posts {
+		s.setUsers(post)
+	}
+	if !s.LoadOrganizations {
+		return nil
+	}
+	if err := s.loadOrganizations(ctx, s.missingOrganizations(posts)); err != nil {
+		return fmt.Errorf("cannot load organizations:%w", err)
+	}
+	for _, post := range posts {
+		s.setUsers(post)
+	}
+	return nil
+}
+
+//loadContentTags sets content tags of the post if LoadContentTags is set
+func (s *Client) loadContentTags(ctx context.Context, post *Post) error {
+	if !s.LoadContentTags {
+		return nil
+	}
+	if err := s.setContentTags(ctx, post); err != nil {
+		return fmt.Errorf("cannot load content tags for postID=%d: %w", post.ID, err)
+	}
+	return nil
+}
+
+//retry calls f until it succeeds or Retries are exhausted, with exponential backoff.
+//Requests failed with not found or unauthorized errors are not retried
+func (s *Client) retry(ctx context.Context, f func() error) error {
+	retries := s.Retries
+	if retries == 0 {
+		retries = defaultRetries
+	}
+	backoff := s.RetryBackoff
+	if backoff == 0 {
+		backoff = defaultRetryBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= retries || errors.Is(err, ErrNotFound) || errors.Is(err, ErrUnauthorized) {
+			return err
 		}
-		if response.NextPage == "" {
-			break
+		select {
+		case <-time.After(backoff << uint(attempt)):
+		case <-ctx.Done():
+			return err
 		}
-		url = response.NextPage
-		page++
 	}
-	close(loadPostsCh)
-	routinesWG.Wait()
-	err := s.loadUsers(usersToLoad)
+}
+
+//GetPost returns a post by its ID with comments, votes and users. The post is marked incomplete
+//if its comments or votes cannot be loaded
+func (s *Client) GetPost(ctx context.Context, id int64) (*Post, error) {
+	return s.GetPostParts(ctx, id, true, true)
+}
+
+//GetPostParts returns a post by its ID with users, and with comments and votes if they are requested
+func (s *Client) GetPostParts(ctx context.Context, id int64, comments, votes bool) (*Post, error) {
+	var response postResponse
+	err := s.get(ctx, fmt.Sprintf("%s/api/v2/community/posts/%d.json", s.BaseURL, id), &response)
 	if err != nil {
-		return nil, nil, err
+		return nil, fmt.Errorf("error while getting post %d: %w", id, err)
+	}
+	if response.Post == nil {
+		return nil, fmt.Errorf("post %d is not found in response", id)
 	}
-	s.setUsers(posts)
-	return posts, errs, fatalError
+	if err := s.loadDetails(ctx, response.Post, comments, votes, func(err error) {}); err != nil {
+		return nil, err
+	}
+	return response.Post, nil
 }
 
-func (s *Client) setUsers(posts []*Post) {
+//missingOrganizations returns IDs of organizations of posts, comments and votes authors which are not loaded yet.
+//Users must be set
+func (s *Client) missingOrganizations(posts []*Post) []int64 {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	seen := make(map[int64]bool)
+	missing := make([]int64, 0)
 	for _, post := range posts {
-		post.Author = s.Users[post.AuthorID]
-		for _, comment := range post.Comments {
-			comment.Author = s.Users[comment.AuthorID]
-		}
-		for _, vote := range post.UserVotes {
-			vote.User = s.Users[vote.UserID]
+		s.forEachUser(post, func(user *User) {
+			id := user.OrganizationID
+			if id == 0 || seen[id] {
+				return
+			}
+			seen[id] = true
+			if _, ok := s.Organizations[id]; !ok {
+				missing = append(missing, id)
+			}
+		})
+	}
+	return missing
+}
+
+//missingUsers returns IDs of posts, comments and votes authors which are not loaded yet.
+//It counts side-loaded users of posts
+func (s *Client) missingUsers(posts []*Post) []int64 {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	seen := make(map[int64]bool)
+	missing := make([]int64, 0)
+	var sideloaded int
+	for _, post := range posts {
+		forEachUserID(post, func(id int64) {
+			if id == 0 || seen[id] {
+				return
+			}
+			seen[id] = true
+			if s.sideloaded[id] {
+				delete(s.sideloaded, id)
+				sideloaded++
+			}
+			if _, ok := s.Users[id]; !ok {
+				missing = append(missing, id)
+			}
+		})
+	}
+	if sideloaded > 0 {
+		s.statsMu.Lock()
+		s.stats.SideloadedUsers += sideloaded
+		s.statsMu.Unlock()
+	}
+	return missing
+}
+
+//unknownUsers adds IDs of post, comments and votes authors which are not loaded yet to ids
+func (s *Client) unknownUsers(post *Post, ids map[int64]bool) {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	forEachUserID(post, func(id int64) {
+		if _, ok := s.Users[id]; !ok && id != 0 {
+			ids[id] = true
 		}
+	})
+}
+
+//forEachUserID calls f for IDs of the post author, comments authors and voters
+func forEachUserID(post *Post, f func(id int64)) {
+	f(post.AuthorID)
+	for _, comment := range post.Comments {
+		f(comment.AuthorID)
+	}
+	for _, vote := range post.UserVotes {
+		f(vote.UserID)
 	}
 }
 
-func (s *Client) detailsLoader(postsCh <-chan *Post, inParallel int) (<-chan *Post, <-chan int64, <-chan error) {
+//sequencedPost is a post with its position in the posts list. Post is nil if its details failed to load
+type sequencedPost struct {
+	seq  int
+	post *Post
+}
+
+//detailsLoader loads details of posts in parallel and sends loaded posts in the order they are received from postsCh.
+//Comments and votes are loaded by inParallel workers, missing users of loaded posts are collected and requested
+//in batches of up to userBatchSize, when workers have no more posts to pass on. Posts failed to load are reported
+//to the error channel only
+func (s *Client) detailsLoader(ctx context.Context, postsCh <-chan *Post, inParallel int) (<-chan *Post, <-chan error) {
 	errCh := make(chan error)
 	resCh := make(chan *Post)
-	usersCh := make(chan int64)
+	seqCh := make(chan sequencedPost)
+	loadedCh := make(chan sequencedPost)
+	//window limits posts loaded ahead of a slow one, a slot is released when a post is sent in order.
+	//It is large enough to collect a full batch of users
+	windowSize := inParallel * 4
+	if windowSize < userBatchSize {
+		windowSize = userBatchSize
+	}
+	window := make(chan struct{}, windowSize)
+	var dispatched int64 // posts sent to workers, accessed atomically
+	go func() {
+		defer close(seqCh)
+		seq := 0
+		for post := range postsCh {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			atomic.AddInt64(&dispatched, 1)
+			select {
+			case seqCh <- sequencedPost{seq: seq, post: post}:
+			case <-ctx.Done():
+				return
+			}
+			seq++
+		}
+	}()
 	var routinesWG sync.WaitGroup
 	routinesWG.Add(inParallel)
 	go func() {
 		routinesWG.Wait()
-		close(errCh)
-		close(usersCh)
-		close(resCh)
+		close(loadedCh)
 	}()
 	for i := 0; i < inParallel; i++ {
 		go func() {
-			for post := range postsCh {
-				usersCh <- post.AuthorID
-				if post.CommentCount > 0 {
-					comments, err := s.getComments(post.ID)
-					if err != nil {
-						errCh <- err
-						continue
-					}
-					post.Comments = comments
-					for _, comment := range comments {
-						usersCh <- comment.AuthorID
-					}
+			defer routinesWG.Done()
+			for item := range seqCh {
+				if ctx.Err() != nil {
+					return
 				}
-				if post.VoteCount > 0 {
-					votes, err := s.getVotes(post.ID)
-					if err != nil {
-						errCh <- err
-						continue
-					}
-					post.UserVotes = votes
-					for _, v := range votes {
-						usersCh <- v.UserID
-					}
+				if reason := s.Filter.Skip(item.post); reason != "" {
+					errCh <- &feedback.SkippedError{ID: strconv.FormatInt(item.post.ID, 10), Title: item.post.Title, Reason: reason}
+					item.post = nil
+				} else {
+					s.loadContent(ctx, item.post, true, true, func(err error) { errCh <- err })
+				}
+				select {
+				case loadedCh <- item:
+				case <-ctx.Done():
+					return
 				}
-				resCh <- post
 			}
-			routinesWG.Done()
 		}()
 	}
-	return resCh, usersCh, errCh
+	go func() {
+		defer close(errCh)
+		defer close(resCh)
+		var received int64
+		var held []sequencedPost
+		heldUsers := make(map[int64]bool)
+		pending := make(map[int]*Post)
+		next := 0
+		//flush loads users of held posts and makes them pending
+		flush := func() {
+			if len(held) == 0 || ctx.Err() != nil {
+				return
+			}
+			posts := make([]*Post, len(held))
+			for i, item := range held {
+				posts[i] = item.post
+			}
+			usersErr := s.loadPostsUsers(ctx, posts)
+			for _, item := range held {
+				var err error
+				if usersErr != nil {
+					err = fmt.Errorf("cannot load users for postID=%d: %w", item.post.ID, usersErr)
+				} else {
+					err = s.loadContentTags(ctx, item.post)
+				}
+				if err != nil {
+					errCh <- err
+					item.post = nil
+				}
+				pending[item.seq] = item.post
+			}
+			held = held[:0]
+			heldUsers = make(map[int64]bool)
+		}
+		//send sends pending posts in order, it returns false if ctx is cancelled
+		send := func() bool {
+			for {
+				post, ok := pending[next]
+				if !ok {
+					return true
+				}
+				delete(pending, next)
+				next++
+				<-window
+				if post == nil {
+					continue
+				}
+				select {
+				case resCh <- post:
+				case <-ctx.Done():
+					return false
+				}
+			}
+		}
+		for item := range loadedCh {
+			received++
+			if item.post == nil {
+				pending[item.seq] = nil
+			} else {
+				held = append(held, item)
+				s.unknownUsers(item.post, heldUsers)
+			}
+			//no more posts are coming from workers until the next one is dispatched, don't wait for it
+			if len(heldUsers) >= userBatchSize || atomic.LoadInt64(&dispatched) == received {
+				flush()
+			}
+			if !send() {
+				//drain loadedCh, so workers are not blocked
+				for range loadedCh {
+				}
+				return
+			}
+		}
+		flush()
+		send()
+	}()
+	return resCh, errCh
 }
 
-func (s *Client) loadUsers(ids []int64) error {
-	//split ids into batches
-	batchSize := 100
-	batches := make([][]int64, 0, (len(ids)+batchSize-1)/batchSize)
-
-	for batchSize < len(ids) {
-		ids, batches = ids[batchSize:], append(batches, ids[0:batchSize])
-	}
-	batches = append(batches, ids)
-	for _, batch := range batches {
+func (s *Client) loadUsers(ctx context.Context, ids []int64) error {
+	for _, batch := range batches(ids, userBatchSize) {
 		url := fmt.Sprintf("%s/api/v2/users/show_many.json?ids=%s", s.BaseURL, idsToString(batch, ","))
 		var response usersResponse
-		err := s.get(url, &response)
+		err := s.get(ctx, url, &response)
 		if err != nil {
 			return fmt.Errorf("error while getting batch of users: %w", err)
 		}
+		s.usersMu.Lock()
+		for _, id := range batch {
+			s.Users[id] = nil // users missing in response are deleted, don't request them again
+		}
 		for _, user := range response.Users {
 			s.Users[user.ID] = user
 		}
+		s.usersMu.Unlock()
 	}
 	return nil
 }
 
-//getComments return all comments for specific post
-func (s *Client) getComments(postID int64) ([]*Comment, error) {
-	comments := make([]*Comment, 0)
-	url := fmt.Sprintf("%s/api/v2/community/posts/%d/comments.json?sort_by=created_at", s.BaseURL, postID)
-	page := 0
-	for true {
-		var response commentsResponse
-		err := s.get(url, &response)
+//addSideloadedUsers adds users side-loaded with a list request. Loaded users are not replaced,
+//as they are shared by posts
+func (s *Client) addSideloadedUsers(users []*User) {
+	if len(users) == 0 {
+		return
+	}
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	if s.Users == nil {
+		s.Users = make(map[int64]*User)
+	}
+	if s.sideloaded == nil {
+		s.sideloaded = make(map[int64]bool)
+	}
+	for _, user := range users {
+		if _, ok := s.Users[user.ID]; ok {
+			continue
+		}
+		s.Users[user.ID] = user
+		s.sideloaded[user.ID] = true
+	}
+}
+
+//loadOrganizations loads organizations by IDs in batches of 100
+func (s *Client) loadOrganizations(ctx context.Context, ids []int64) error {
+	for _, batch := range batches(ids, 100) {
+		url := fmt.Sprintf("%s/api/v2/organizations/show_many.json?ids=%s", s.BaseURL, idsToString(batch, ","))
+		var response organizationsResponse
+		err := s.get(ctx, url, &response)
 		if err != nil {
-			return nil, fmt.Errorf("error while getting page %d of comments for postID=%d: %w", page, err, postID)
+			return fmt.Errorf("error while getting batch of organizations: %w", err)
 		}
-		if response.Comments == nil && response.NextPage != "" {
-			return nil, fmt.Errorf("Comments are not found on page %d while next page is present, postID=%d", page, postID)
+		s.usersMu.Lock()
+		if s.Organizations == nil {
+			s.Organizations = make(map[int64]*Organization)
 		}
-		comments = append(comments, response.Comments...)
-		if response.NextPage == "" {
-			return comments, nil
+		for _, id := range batch {
+			s.Organizations[id] = nil // organizations missing in response are deleted, don't request them again
+		}
+		for _, organization := range response.Organizations {
+			s.Organizations[organization.ID] = organization
+		}
+		s.usersMu.Unlock()
+	}
+	return nil
+}
+
+//setContentTags loads content tags of the post which are not loaded yet and sets them to the post
+func (s *Client) setContentTags(ctx context.Context, post *Post) error {
+	tags := make([]*ContentTag, 0, len(post.ContentTagIDs))
+	for _, id := range post.ContentTagIDs {
+		s.usersMu.Lock()
+		tag, ok := s.ContentTags[id]
+		s.usersMu.Unlock()
+		if !ok {
+			var response contentTagResponse
+			err := s.get(ctx, fmt.Sprintf("%s/api/v2/guide/content_tags/%s", s.BaseURL, id), &response)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("error while getting content tag %s: %w", id, err)
+			}
+			tag = response.ContentTag // nil for deleted tags, don't request them again
+			s.usersMu.Lock()
+			if s.ContentTags == nil {
+				s.ContentTags = make(map[string]*ContentTag)
+			}
+			s.ContentTags[id] = tag
+			s.usersMu.Unlock()
 		}
-		url = response.NextPage
-		page++
+		if tag != nil {
+			tags = append(tags, tag)
+		}
+	}
+	post.ContentTags = tags
+	return nil
+}
+
+//batchCount returns number of batches of batchSize for n items
+func batchCount(n, batchSize int) int {
+	return (n + batchSize - 1) / batchSize
+}
+
+//batches splits ids into batches of batchSize
+func batches(ids []int64, batchSize int) [][]int64 {
+	batches := make([][]int64, 0, batchCount(len(ids), batchSize))
+	for batchSize < len(ids) {
+		ids, batches = ids[batchSize:], append(batches, ids[0:batchSize])
+	}
+	if len(ids) > 0 {
+		batches = append(batches, ids)
+	}
+	return batches
+}
+
+//getComments return all comments for specific post
+func (s *Client) getComments(ctx context.Context, postID int64) ([]*Comment, error) {
+	comments := make([]*Comment, 0)
+	url := fmt.Sprintf("%s/api/v2/community/posts/%d/comments.json?sort_by=created_at&include=users", s.BaseURL, postID)
+	err := s.paginate(ctx, fmt.Sprintf("comments for postID=%d", postID), url, func() page { return &commentsResponse{} }, func(p page) error {
+		response := p.(*commentsResponse)
+		s.addSideloadedUsers(response.Users)
+		comments = append(comments, response.Comments...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil, nil
+	return comments, nil
 }
 
 //getVotes return all votes, as a list of users voted for specific post
-func (s *Client) getVotes(postID int64) ([]*Vote, error) {
+func (s *Client) getVotes(ctx context.Context, postID int64) ([]*Vote, error) {
 	votes := make([]*Vote, 0)
 	url := fmt.Sprintf("%s/api/v2/community/posts/%d/votes.json?sort_by=created_at", s.BaseURL, postID)
-	page := 0
-	for true {
-		var response votesResponse
-		err := s.get(url, &response)
-		if err != nil {
-			return nil, fmt.Errorf("error while getting page %d of votes for postID=%d: %w", page, err, postID)
-		}
-		if response.Votes == nil && response.NextPage != "" {
-			return nil, fmt.Errorf("Posts are not found on page %d while next page is present, postID=%d", page, postID)
-		}
-		votes = append(votes, response.Votes...)
-		if response.NextPage == "" {
-			return votes, nil
-		}
-		url = response.NextPage
-		page++
+	err := s.paginate(ctx, fmt.Sprintf("votes for postID=%d", postID), url, func() page { return &votesResponse{} }, func(p page) error {
+		votes = append(votes, p.(*votesResponse).Votes...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil, nil
+	return votes, nil
 }
 
-func (s *Client) get(url string, dst interface{}) error {
-	req, err := http.NewRequest("GET", url, nil)
+func (s *Client) get(ctx context.Context, url string, dst interface{}) error {
+	s.statsMu.Lock()
+	s.stats.Requests++
+	s.statsMu.Unlock()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
-	req.SetBasicAuth(s.Username, s.Password)
-	cli := &http.Client{}
-	resp, err := cli.Do(req)
+	if s.Auth != nil {
+		s.Auth.Authenticate(req)
+	}
+	resp, err := s.httpClient().Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return fmt.Errorf("cannot read response body:%w", err)
 	}
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("error while making request to %s: %d - %s", url, resp.StatusCode, body)
+		return &APIError{StatusCode: resp.StatusCode, Endpoint: req.URL.Path, Body: string(body)}
 	}
 	err = json.Unmarshal(body, dst)
 	if err != nil {
@@ -313,6 +867,36 @@ func (s *Client) get(url string, dst interface{}) error {
 	return nil
 }
 
+//Download writes content of file at url, e.g. an image attached to a post, to w. The download is not limited by
+//the client timeout, it fails if no data is received for the timeout. Credentials are sent only if url points to Zendesk
+func (s *Client) Download(ctx context.Context, url string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	if s.Auth != nil && strings.HasPrefix(url, s.BaseURL+"/") {
+		s.Auth.Authenticate(req)
+	}
+	resp, err := httpclient.Download(s.httpClient(), req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Endpoint: req.URL.Path, Body: string(body)}
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (s *Client) httpClient() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
+	}
+	return httpclient.Default
+}
+
 func idsToString(ids []int64, delim string) string {
 	var buffer bytes.Buffer
 	for i := 0; i < len(ids); i++ {
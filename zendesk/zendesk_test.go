@@ -0,0 +1,118 @@
+package zendesk_test
+
+import (
+	"context"
+	"github.com/Pleexy/zendesk-to-canny/zendesk"
+	"github.com/Pleexy/zendesk-to-canny/zendesk/zendesktest"
+	"strings"
+	"testing"
+)
+
+func TestGetPostsSideloadsUsers(t *testing.T) {
+	const topic = "100-Ideas"
+	z := zendesktest.NewServer()
+	defer z.Close()
+	z.AddUsers(
+		&zendesk.User{ID: 11, Name: "Ann"},
+		&zendesk.User{ID: 12, Name: "Bob"},
+		&zendesk.User{ID: 13, Name: "Cid"},
+		&zendesk.User{ID: 14, Name: "Dee"},
+	)
+	z.AddPost(topic, &zendesk.Post{ID: 1, AuthorID: 11, CommentCount: 1, VoteCount: 1},
+		[]*zendesk.Comment{{ID: 101, AuthorID: 12}}, []*zendesk.Vote{{ID: 201, UserID: 13}})
+	//users of post 2 are side-loaded only, so show_many is not requested for it
+	z.AddPost(topic, &zendesk.Post{ID: 2, AuthorID: 14, CommentCount: 1},
+		[]*zendesk.Comment{{ID: 102, AuthorID: 11}}, nil)
+
+	client := &zendesk.Client{BaseURL: z.URL}
+	posts, errs, err := client.GetPosts(context.Background(), topic, 1, nil, nil)
+	if err != nil || len(errs) > 0 {
+		t.Fatalf("GetPosts: %v %v", err, errs)
+	}
+	for _, post := range posts {
+		if post.Author == nil || post.Comments[0].Author == nil {
+			t.Errorf("users of post %d are not set", post.ID)
+		}
+	}
+	var showMany []string
+	for _, req := range z.Requests() {
+		if strings.Contains(req, "show_many") {
+			showMany = append(showMany, req)
+		}
+	}
+	if len(showMany) != 1 || !strings.Contains(showMany[0], "ids=13") {
+		t.Errorf("only the voter must be requested by show_many, got %v", showMany)
+	}
+	//users 11, 12 and 14 are side-loaded, so one show_many batch is saved
+	if stats := client.Stats(); stats.Requests != len(z.Requests()) || stats.SideloadedUsers != 3 || stats.SavedRequests != 1 {
+		t.Errorf("unexpected stats %+v for %d requests", stats, len(z.Requests()))
+	}
+}
+
+func TestStreamPostsKeepsOrder(t *testing.T) {
+	const topic = "100-Ideas"
+	z := zendesktest.NewServer()
+	defer z.Close()
+	z.PageSize = 1
+	//the first post takes the longest to load
+	comments := make([]*zendesk.Comment, 20)
+	for i := range comments {
+		comments[i] = &zendesk.Comment{ID: int64(100 + i)}
+	}
+	z.AddPost(topic, &zendesk.Post{ID: 1}, comments, nil)
+	for i := 2; i <= 10; i++ {
+		z.AddPost(topic, &zendesk.Post{ID: int64(i)}, nil, nil)
+	}
+	client := &zendesk.Client{BaseURL: z.URL}
+	stream := client.StreamPosts(context.Background(), topic, 4, func(err error) {
+		t.Errorf("unexpected error: %v", err)
+	})
+	var ids []int64
+	for post := range stream.Posts {
+		ids = append(ids, post.ID)
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("StreamPosts: %v", err)
+	}
+	for i, id := range ids {
+		if id != int64(i+1) {
+			t.Fatalf("posts are not in the listing order: %v", ids)
+		}
+	}
+	if len(ids) != 10 {
+		t.Errorf("loaded %d posts, want 10", len(ids))
+	}
+}
+
+func TestStreamPostsBatchesUsers(t *testing.T) {
+	const topic = "100-Ideas"
+	const posts = 40
+	z := zendesktest.NewServer()
+	defer z.Close()
+	z.PageSize = posts
+	for i := 1; i <= posts; i++ {
+		voter := int64(1000 + i)
+		z.AddUsers(&zendesk.User{ID: voter, Name: "Voter"})
+		z.AddPost(topic, &zendesk.Post{ID: int64(i)}, nil, []*zendesk.Vote{{ID: int64(i), UserID: voter}})
+	}
+	client := &zendesk.Client{BaseURL: z.URL}
+	loaded, errs, err := client.GetPosts(context.Background(), topic, 4, nil, nil)
+	if err != nil || len(errs) > 0 {
+		t.Fatalf("GetPosts: %v %v", err, errs)
+	}
+	for _, post := range loaded {
+		if post.UserVotes[0].User == nil {
+			t.Errorf("voter of post %d is not set", post.ID)
+		}
+	}
+	var showMany int
+	for _, req := range z.Requests() {
+		if strings.Contains(req, "show_many") {
+			showMany++
+		}
+	}
+	//voters of posts loaded together are requested by one show_many
+	if showMany > posts/4 {
+		t.Errorf("%d show_many requests for %d posts, users must be requested in batches", showMany, posts)
+	}
+}
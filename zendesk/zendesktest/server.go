@@ -0,0 +1,284 @@
+//Package zendesktest provides an in-memory fake of Zendesk Help Center API used by zendesk.Client
+package zendesktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Pleexy/zendesk-to-canny/zendesk"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//Server is a fake Zendesk API. It serves topic posts, post comments and votes with cursor or next_page pagination,
+//users/show_many, organizations/show_many and content tags. Use Lock/Unlock to modify data fields while the server is in use
+type Server struct {
+	*httptest.Server
+	PageSize int // items per page, default 30. Cursor pages are limited by page[size] too
+	//MaxOffsetPages is a number of pages served with offset pagination, next_page of the last one is empty
+	//as Zendesk does. Default 100
+	MaxOffsetPages int
+	//OffsetOnly disables cursor pagination, page[size] is ignored
+	OffsetOnly    bool
+	Topics        map[string]*zendesk.Topic    // topics by ID
+	Posts         map[string][]*zendesk.Post   // posts by topic ID
+	Comments      map[int64][]*zendesk.Comment // comments by post ID
+	Votes         map[int64][]*zendesk.Vote    // votes by post ID
+	Users         map[int64]*zendesk.User
+	Organizations map[int64]*zendesk.Organization
+	ContentTags   map[string]*zendesk.ContentTag
+	Files         map[string][]byte // attachments by path, e.g. /hc/user_images/1.png
+	//Fail returns HTTP status code to fail a request with, 0 serves the request normally. Optional
+	Fail func(r *http.Request) int
+
+	mu       sync.Mutex
+	requests []string
+}
+
+//NewServer creates and starts a fake Zendesk server. Use Server.URL as zendesk.Client BaseURL
+func NewServer() *Server {
+	s := &Server{
+		PageSize:      30,
+		Topics:        make(map[string]*zendesk.Topic),
+		Posts:         make(map[string][]*zendesk.Post),
+		Comments:      make(map[int64][]*zendesk.Comment),
+		Votes:         make(map[int64][]*zendesk.Vote),
+		Users:         make(map[int64]*zendesk.User),
+		Organizations: make(map[int64]*zendesk.Organization),
+		ContentTags:   make(map[string]*zendesk.ContentTag),
+		Files:         make(map[string][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+//AddPost adds a post to a topic with its comments and votes, and sets post comment count.
+//Vote count and sum are computed from votes unless they are set, e.g. to simulate votes lost by Zendesk
+func (s *Server) AddPost(topic string, post *zendesk.Post, comments []*zendesk.Comment, votes []*zendesk.Vote) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post.CommentCount = len(comments)
+	if post.VoteCount == 0 {
+		post.VoteCount = len(votes)
+	}
+	if post.VoteSum == 0 {
+		for _, vote := range votes {
+			if vote.Value < 0 {
+				post.VoteSum--
+			} else {
+				post.VoteSum++
+			}
+		}
+	}
+	s.Posts[topic] = append(s.Posts[topic], post)
+	s.Comments[post.ID] = comments
+	s.Votes[post.ID] = votes
+}
+
+//AddUsers adds users returned by show_many
+func (s *Server) AddUsers(users ...*zendesk.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range users {
+		s.Users[user.ID] = user
+	}
+}
+
+//AddOrganizations adds organizations returned by show_many
+func (s *Server) AddOrganizations(organizations ...*zendesk.Organization) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, organization := range organizations {
+		s.Organizations[organization.ID] = organization
+	}
+}
+
+//Lock locks server data
+func (s *Server) Lock() {
+	s.mu.Lock()
+}
+
+//Unlock unlocks server data
+func (s *Server) Unlock() {
+	s.mu.Unlock()
+}
+
+//Requests returns paths of all requests served, including query
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.URL.RequestURI())
+	if s.Fail != nil {
+		if status := s.Fail(r); status != 0 {
+			http.Error(w, `{"error":"fake failure"}`, status)
+			return
+		}
+	}
+	if file, ok := s.Files[r.URL.Path]; ok {
+		_, _ = w.Write(file)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 5 && parts[2] == "community" && parts[3] == "topics" && strings.HasSuffix(parts[4], ".json"):
+		topic, ok := s.Topics[strings.TrimSuffix(parts[4], ".json")]
+		if !ok {
+			http.Error(w, `{"error":"RecordNotFound"}`, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"topic": topic})
+	case len(parts) == 6 && parts[2] == "community" && parts[3] == "topics" && parts[5] == "posts.json":
+		posts, ok := s.Posts[parts[4]]
+		if !ok {
+			http.Error(w, `{"error":"RecordNotFound"}`, http.StatusNotFound)
+			return
+		}
+		items := make([]interface{}, len(posts))
+		authors := make([]int64, len(posts))
+		for i, post := range posts {
+			items[i], authors[i] = post, post.AuthorID
+		}
+		s.writePage(w, r, "posts", items, authors)
+	case len(parts) == 5 && parts[2] == "community" && parts[3] == "posts" && strings.HasSuffix(parts[4], ".json"):
+		postID, _ := strconv.ParseInt(strings.TrimSuffix(parts[4], ".json"), 10, 64)
+		for _, posts := range s.Posts {
+			for _, post := range posts {
+				if post.ID == postID {
+					writeJSON(w, map[string]interface{}{"post": post})
+					return
+				}
+			}
+		}
+		http.Error(w, `{"error":"RecordNotFound"}`, http.StatusNotFound)
+	case len(parts) == 6 && parts[2] == "community" && parts[3] == "posts" && parts[5] == "comments.json":
+		postID, _ := strconv.ParseInt(parts[4], 10, 64)
+		comments := s.Comments[postID]
+		items := make([]interface{}, len(comments))
+		authors := make([]int64, len(comments))
+		for i, comment := range comments {
+			items[i], authors[i] = comment, comment.AuthorID
+		}
+		s.writePage(w, r, "comments", items, authors)
+	case len(parts) == 6 && parts[2] == "community" && parts[3] == "posts" && parts[5] == "votes.json":
+		postID, _ := strconv.ParseInt(parts[4], 10, 64)
+		votes := s.Votes[postID]
+		items := make([]interface{}, len(votes))
+		for i, vote := range votes {
+			items[i] = vote
+		}
+		s.writePage(w, r, "votes", items, nil)
+	case r.URL.Path == "/api/v2/users/show_many.json":
+		users := make([]*zendesk.User, 0)
+		for _, idStr := range strings.Split(r.URL.Query().Get("ids"), ",") {
+			id, _ := strconv.ParseInt(idStr, 10, 64)
+			if user, ok := s.Users[id]; ok {
+				users = append(users, user)
+			}
+		}
+		writeJSON(w, map[string]interface{}{"users": users, "count": len(users), "next_page": nil})
+	case len(parts) == 5 && parts[2] == "guide" && parts[3] == "content_tags":
+		tag, ok := s.ContentTags[parts[4]]
+		if !ok {
+			http.Error(w, `{"error":"RecordNotFound"}`, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"content_tag": tag})
+	case r.URL.Path == "/api/v2/organizations/show_many.json":
+		organizations := make([]*zendesk.Organization, 0)
+		for _, idStr := range strings.Split(r.URL.Query().Get("ids"), ",") {
+			id, _ := strconv.ParseInt(idStr, 10, 64)
+			if organization, ok := s.Organizations[id]; ok {
+				organizations = append(organizations, organization)
+			}
+		}
+		writeJSON(w, map[string]interface{}{"organizations": organizations, "count": len(organizations), "next_page": nil})
+	default:
+		http.Error(w, `{"error":"InvalidEndpoint"}`, http.StatusNotFound)
+	}
+}
+
+//writePage writes a page of items selected by page query param. authors are user IDs of items,
+//they are side-loaded with include=users. nil authors means the list doesn't support side-loading
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, name string, items []interface{}, authors []int64) {
+	var response map[string]interface{}
+	var from, to int
+	if size, _ := strconv.Atoi(r.URL.Query().Get("page[size]")); size > 0 && !s.OffsetOnly {
+		response, from, to = s.cursorPage(r, name, items, size)
+	} else {
+		response, from, to = s.offsetPage(r, name, items)
+	}
+	if authors != nil && r.URL.Query().Get("include") == "users" {
+		users := make([]*zendesk.User, 0)
+		seen := make(map[int64]bool)
+		for _, id := range authors[from:to] {
+			if user, ok := s.Users[id]; ok && !seen[id] {
+				seen[id] = true
+				users = append(users, user)
+			}
+		}
+		response["users"] = users
+	}
+	writeJSON(w, response)
+}
+
+//offsetPage returns a page of items selected by page query param and its bounds
+func (s *Server) offsetPage(r *http.Request, name string, items []interface{}) (map[string]interface{}, int, int) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	from := (page - 1) * s.PageSize
+	to := from + s.PageSize
+	if from > len(items) {
+		from = len(items)
+	}
+	var nextPage interface{}
+	if to < len(items) && page < s.MaxOffsetPages {
+		next := *r.URL
+		q := next.Query()
+		q.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = q.Encode()
+		nextPage = fmt.Sprintf("%s%s", s.URL, next.RequestURI())
+	} else if to > len(items) {
+		to = len(items)
+	}
+	return map[string]interface{}{name: items[from:to], "count": len(items), "next_page": nextPage}, from, to
+}
+
+//cursorPage returns a page of items after the cursor selected by page[after] query param and its bounds
+func (s *Server) cursorPage(r *http.Request, name string, items []interface{}, size int) (map[string]interface{}, int, int) {
+	if size > s.PageSize {
+		size = s.PageSize
+	}
+	from, _ := strconv.Atoi(r.URL.Query().Get("page[after]"))
+	if from > len(items) {
+		from = len(items)
+	}
+	to := from + size
+	if to > len(items) {
+		to = len(items)
+	}
+	links := map[string]interface{}{"next": nil}
+	if to < len(items) {
+		next := *r.URL
+		q := next.Query()
+		q.Set("page[after]", strconv.Itoa(to))
+		next.RawQuery = q.Encode()
+		links["next"] = fmt.Sprintf("%s%s", s.URL, next.RequestURI())
+	}
+	meta := map[string]interface{}{"has_more": to < len(items), "after_cursor": strconv.Itoa(to)}
+	return map[string]interface{}{name: items[from:to], "meta": meta, "links": links}, from, to
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}